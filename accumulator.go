@@ -0,0 +1,55 @@
+package main
+
+import "sync"
+
+// videoAccumulator collects fetched videos and lets concurrent enrichment
+// goroutines update entries in place while pagination is still appending.
+type videoAccumulator struct {
+	mu      sync.Mutex
+	items   []Video
+	indexes map[string][]int
+}
+
+func newVideoAccumulator() *videoAccumulator {
+	return &videoAccumulator{indexes: make(map[string][]int)}
+}
+
+func (acc *videoAccumulator) add(videos ...Video) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	for _, video := range videos {
+		acc.indexes[video.VideoId] = append(acc.indexes[video.VideoId], len(acc.items))
+		acc.items = append(acc.items, video)
+	}
+}
+
+// update applies fn to every entry with the given VideoId, so duplicate
+// playlist entries stay consistent.
+func (acc *videoAccumulator) update(videoId string, fn func(video *Video)) {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	for _, i := range acc.indexes[videoId] {
+		fn(&acc.items[i])
+	}
+}
+
+func (acc *videoAccumulator) len() int {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	return len(acc.items)
+}
+
+func (acc *videoAccumulator) videos() []Video {
+	acc.mu.Lock()
+	defer acc.mu.Unlock()
+
+	if acc.items == nil {
+		return nil
+	}
+	videos := make([]Video, len(acc.items))
+	copy(videos, acc.items)
+	return videos
+}
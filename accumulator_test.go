@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFetchPlaylistEnrichesPagesConcurrently is meant for -race: the
+// metadata of each page is fetched while later pages are still appended.
+func TestFetchPlaylistEnrichesPagesConcurrently(t *testing.T) {
+	const pageCount, pageSize = 6, 50
+	videos := testPlaylist(pageCount * pageSize).Playlist
+	api := &fakeAPI{videosDelay: 20 * time.Millisecond}
+	for start := 0; start < len(videos); start += pageSize {
+		api.pages = append(api.pages, replayPage(videos[start:start+pageSize]))
+	}
+	serveFakeAPI(t, api)
+
+	config := testConfig(t, map[string]any{"fetchMetadata": true})
+	client, err := newAPIClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := fetchPlaylist(config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fetched) != len(videos) {
+		t.Fatalf("fetched %d videos, want %d", len(fetched), len(videos))
+	}
+	for i, video := range fetched {
+		if video.VideoId != videos[i].VideoId {
+			t.Errorf("video %d is %s, want %s", i, video.VideoId, videos[i].VideoId)
+		}
+		if video.Availability != availabilityPublic || video.Duration != "PT30S" || !video.IsShort {
+			t.Errorf("video %s was not enriched: %+v", video.VideoId, video)
+		}
+	}
+	if calls := api.videosCalls.Load(); calls != pageCount {
+		t.Errorf("made %d videos calls, want one per page, %d", calls, pageCount)
+	}
+	if most := api.maxVideosInFlight.Load(); most < 2 {
+		t.Errorf("at most %d videos calls were in flight at once, want the pages enriched concurrently", most)
+	}
+}
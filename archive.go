@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchivedSnapshot is one history file gathered by -archive.
+type ArchivedSnapshot struct {
+	TakenAt  time.Time       `json:"takenAt"`
+	Kind     string          `json:"kind"`
+	FileName string          `json:"fileName"`
+	Snapshot YoutubePlaylist `json:"snapshot"`
+}
+
+// historySnapshotTime parses the timestamp saveHistory prefixes fileName
+// with, reporting false for files that are not history snapshots of it.
+func historySnapshotTime(name, fileName string) (time.Time, bool) {
+	prefix, found := strings.CutSuffix(name, "_"+fileName)
+	if !found {
+		return time.Time{}, false
+	}
+	// Snapshots sharing a timestamp carry a counter, see historyFileName.
+	if i := strings.LastIndexByte(prefix, '.'); i > 0 && !strings.ContainsAny(prefix[i:], "Z+-:") {
+		prefix = prefix[:i]
+	}
+	t, err := time.Parse(time.RFC3339, prefix)
+	return t, err == nil
+}
+
+// archiveHistory gathers the history snapshots of every configured playlist
+// into its HistoryArchiveFileName, adding to what an earlier -archive
+// gathered. With prune, the snapshot files are removed once archived.
+func archiveHistory(config *Config, prune bool) error {
+	lock, err := acquireLock(config)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	configs, err := config.playlistConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		archive, err := config.readArchive()
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", config.HistoryArchiveFileName, err)
+		}
+		if archive == nil {
+			archive = []ArchivedSnapshot{}
+		}
+		archived := make(map[string]bool)
+		for _, snapshot := range archive {
+			archived[snapshot.FileName] = true
+		}
+
+		var gathered []string
+		for _, history := range []struct{ kind, fileName string }{{"playlist", config.PlaylistFileName}, {"diff", config.DiffFileName}} {
+			kind, fileName := history.kind, history.fileName
+			paths, err := filepath.Glob(filepath.Join(config.DirPath, "*_"+fileName))
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				name := filepath.Base(path)
+				takenAt, ok := historySnapshotTime(name, fileName)
+				if !ok {
+					continue
+				}
+				gathered = append(gathered, path)
+				if archived[name] {
+					continue
+				}
+				snapshot, err := readPlaylistFromFile(*config, name)
+				if err != nil {
+					return fmt.Errorf("error reading %s: %w", name, err)
+				}
+				archive = append(archive, ArchivedSnapshot{TakenAt: takenAt, Kind: kind, FileName: name, Snapshot: snapshot})
+			}
+		}
+		slices.SortStableFunc(archive, func(a, b ArchivedSnapshot) int {
+			return a.TakenAt.Compare(b.TakenAt)
+		})
+
+		config.writeJSONFile(config.HistoryArchiveFileName, archive)
+		if prune {
+			for _, path := range gathered {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Removed %d archived snapshot files\n", len(gathered))
+		}
+	}
+	return nil
+}
+
+func (config Config) readArchive() ([]ArchivedSnapshot, error) {
+	data, err := os.ReadFile(filepath.Join(config.DirPath, config.HistoryArchiveFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isEncrypted(data) {
+		data, err = decrypt(config.encryptionKey, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var archive []ArchivedSnapshot
+	err = json.Unmarshal(data, &archive)
+	return archive, err
+}
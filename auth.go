@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const youtubeReadonlyScope = "https://www.googleapis.com/auth/youtube.readonly"
+
+// googleTokenURL is a variable so tests can point token refreshes at a local
+// server.
+var googleTokenURL = "https://oauth2.googleapis.com/token"
+
+type tokenSource interface {
+	// token returns an access token, exchanging one through client when
+	// the last has expired.
+	token(client *apiClient) (string, error)
+	// identity names the account without revealing its secrets.
+	identity() string
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// exchangeToken posts form to tokenURL with the transport, context and
+// retries of the API requests.
+func exchangeToken(client *apiClient, tokenURL string, form url.Values) (*tokenResponse, error) {
+	var response tokenResponse
+	err := client.retrying("token", func() error {
+		req, err := http.NewRequestWithContext(client.ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := client.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return &statusError{statusCode: resp.StatusCode}
+		}
+		return json.NewDecoder(resp.Body).Decode(&response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	return &response, nil
+}
+
+// expiry refreshes a minute early so a token never expires mid-request.
+func expiry(expiresIn int) time.Time {
+	return time.Now().Add(time.Duration(expiresIn)*time.Second - time.Minute)
+}
+
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyId string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenUri     string `json:"token_uri"`
+}
+
+type serviceAccount struct {
+	mu          sync.Mutex
+	key         serviceAccountKey
+	signer      *rsa.PrivateKey
+	accessToken string
+	expiresAt   time.Time
+}
+
+func newServiceAccount(keyFile string) (*serviceAccount, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("error unmarshalling service account key: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("service account key is missing client_email or private_key")
+	}
+	if key.TokenUri == "" {
+		key.TokenUri = googleTokenURL
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service account private key: %w", err)
+	}
+	signer, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private key is not an RSA key")
+	}
+
+	return &serviceAccount{key: key, signer: signer}, nil
+}
+
+func (account *serviceAccount) token(client *apiClient) (string, error) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	if account.accessToken != "" && time.Now().Before(account.expiresAt) {
+		return account.accessToken, nil
+	}
+
+	assertion, err := account.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	response, err := exchangeToken(client, account.key.TokenUri, form)
+	if err != nil {
+		return "", err
+	}
+
+	account.accessToken = response.AccessToken
+	account.expiresAt = expiry(response.ExpiresIn)
+	return account.accessToken, nil
+}
+
+func (account *serviceAccount) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": account.key.PrivateKeyId,
+	})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   account.key.ClientEmail,
+		"scope": youtubeReadonlyScope,
+		"aud":   account.key.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, account.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+func (account *serviceAccount) identity() string {
+	return "serviceAccount:" + account.key.ClientEmail
+}
+
+// userCredentials authorizes as a YouTube user through an OAuth refresh
+// token, which is required for personal playlists such as Liked videos.
+type userCredentials struct {
+	mu           sync.Mutex
+	clientId     string
+	clientSecret string
+	refreshToken string
+	accessToken  string
+	expiresAt    time.Time
+}
+
+func (credentials *userCredentials) token(client *apiClient) (string, error) {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+
+	if credentials.accessToken != "" && time.Now().Before(credentials.expiresAt) {
+		return credentials.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", credentials.clientId)
+	form.Set("client_secret", credentials.clientSecret)
+	form.Set("refresh_token", credentials.refreshToken)
+
+	response, err := exchangeToken(client, googleTokenURL, form)
+	if err != nil {
+		return "", err
+	}
+
+	credentials.accessToken = response.AccessToken
+	credentials.expiresAt = expiry(response.ExpiresIn)
+	return credentials.accessToken, nil
+}
+
+func (credentials *userCredentials) identity() string {
+	return "oauth:" + fingerprint(credentials.refreshToken)
+}
+
+// fingerprint is a short, non-reversible identifier of a secret.
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:6])
+}
+
+var (
+	ErrWatchLaterUnsupported = errors.New("the Watch Later playlist (WL) is not available through the YouTube Data API")
+	ErrHistoryUnsupported    = errors.New("the watch history playlist (HL) is not available through the YouTube Data API")
+	ErrNeedsUserCredentials  = errors.New("the Liked videos playlist (LL) belongs to a user and needs oauthRefreshToken credentials")
+)
+
+// checkSpecialPlaylist rejects the special playlist ids that cannot be
+// tracked. Of the personal playlists only Liked videos (LL) works, and only
+// with OAuth user credentials; Watch Later (WL) and history (HL) have been
+// hidden from the API, which returns them as empty.
+func checkSpecialPlaylist(playlistId string, hasUserCredentials bool) error {
+	switch playlistId {
+	case "WL":
+		return ErrWatchLaterUnsupported
+	case "HL":
+		return ErrHistoryUnsupported
+	case "LL":
+		if !hasUserCredentials {
+			return ErrNeedsUserCredentials
+		}
+	}
+	return nil
+}
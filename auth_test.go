@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTokenExchangeRetriesThroughClient(t *testing.T) {
+	var exchanges atomic.Int64
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exchanges.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	tokenURL := googleTokenURL
+	googleTokenURL = tokenServer.URL
+	defer func() { googleTokenURL = tokenURL }()
+
+	api := &fakeAPI{pages: []PlaylistItemsResponse{replayPage(testPlaylist(2).Playlist)}}
+	serveFakeAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+
+	config := testConfig(t, map[string]any{"apiKey": "", "oauthClientId": "id", "oauthClientSecret": "secret",
+		"oauthRefreshToken": "refresh", "maxRetries": 2, "retryBaseDelay": "1ms"})
+	if _, err := Run(config, RunOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := exchanges.Load(); n != 2 {
+		t.Errorf("exchanged the token %d times, want a failed attempt and a retry", n)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+func watchURL(videoId string) string {
+	return "https://www.youtube.com/watch?v=" + videoId
+}
+
+// writeChangelog renders changes as markdown meant to be pasted into a chat
+// or issue, grouping entries under one header per kind of change.
+func writeChangelog(w io.Writer, changes *Changes, _ writeOptions) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "# Playlist changes, %s\n", changes.UpdatedAt.Format(time.DateOnly))
+
+	if changes.empty() {
+		fmt.Fprintln(out, "\nNo changes.")
+	}
+	if len(changes.Added) > 0 {
+		fmt.Fprintln(out, "\n## Added")
+		for _, video := range changes.Added {
+			fmt.Fprintf(out, "- Added: %s (%s)\n", video.Title, watchURL(video.VideoId))
+		}
+	}
+	if len(changes.Removed) > 0 {
+		fmt.Fprintln(out, "\n## Removed")
+		for _, video := range changes.Removed {
+			fmt.Fprintf(out, "- Removed: %s (%s)\n", video.Title, watchURL(video.VideoId))
+		}
+	}
+	if len(changes.Renamed) > 0 {
+		fmt.Fprintln(out, "\n## Renamed")
+		for _, rename := range changes.Renamed {
+			fmt.Fprintf(out, "- Renamed: %s → %s (%s)\n", rename.OldTitle, rename.Video.Title, watchURL(rename.Video.VideoId))
+		}
+	}
+
+	if len(changes.Moved) > 0 {
+		fmt.Fprintln(out, "\n## Moved")
+		for _, move := range changes.Moved {
+			fmt.Fprintf(out, "- Moved: %s (%d → %d)\n", move.Video.Title, move.OldPosition, move.NewPosition)
+		}
+	}
+
+	return out.Flush()
+}
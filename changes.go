@@ -0,0 +1,91 @@
+package main
+
+import "time"
+
+// Changes describes how a playlist differs from an earlier snapshot, for
+// outputs that report changes rather than the playlist itself.
+type Changes struct {
+	Added     []Video   `json:"added"`
+	Removed   []Video   `json:"removed"`
+	Renamed   []Rename  `json:"renamed"`
+	Moved     []Move    `json:"moved"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// PlaylistReplaced is set when no video is in both snapshots, which
+	// suggests a different playlist rather than churn.
+	PlaylistReplaced bool `json:"playlistReplaced,omitempty"`
+}
+
+type Rename struct {
+	Video    Video  `json:"video"`
+	OldTitle string `json:"oldTitle"`
+}
+
+// ChangeType is the kind of a Change.
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeRenamed ChangeType = "renamed"
+)
+
+// Change is one added, removed or renamed video, as given to
+// RunOptions.OnChange.
+type Change struct {
+	Type ChangeType
+	// Playlist is the name of the playlist, its Alias or PlaylistId.
+	Playlist string
+	Video    Video
+	// OldTitle is only set for renames.
+	OldTitle string
+}
+
+// each calls fn with every added, removed and renamed video of changes.
+func (changes Changes) each(playlist string, fn func(change Change)) {
+	for _, video := range changes.Added {
+		fn(Change{Type: ChangeAdded, Playlist: playlist, Video: video})
+	}
+	for _, video := range changes.Removed {
+		fn(Change{Type: ChangeRemoved, Playlist: playlist, Video: video})
+	}
+	for _, rename := range changes.Renamed {
+		fn(Change{Type: ChangeRenamed, Playlist: playlist, Video: rename.Video, OldTitle: rename.OldTitle})
+	}
+}
+
+func compare(old, current YoutubePlaylist, options diffOptions) *Changes {
+	changes := &Changes{UpdatedAt: current.UpdatedAt}
+
+	oldVideos := make(map[string]Video)
+	for _, video := range old.Playlist {
+		oldVideos[video.VideoId] = video
+	}
+	currentVideos := make(map[string]bool)
+	shared := false
+	for _, video := range current.Playlist {
+		currentVideos[video.VideoId] = true
+		oldVideo, found := oldVideos[video.VideoId]
+		shared = shared || found
+		if !found {
+			changes.Added = append(changes.Added, video)
+		} else if oldVideo.Title != video.Title && oldVideo.Title != "" && !options.membershipOnly {
+			// Videos imported without credentials have no title yet, which
+			// the first fetch fills in rather than renames.
+			changes.Renamed = append(changes.Renamed, Rename{Video: video, OldTitle: oldVideo.Title})
+		}
+	}
+	for _, video := range old.Playlist {
+		if !currentVideos[video.VideoId] {
+			changes.Removed = append(changes.Removed, video)
+			currentVideos[video.VideoId] = true
+		}
+	}
+
+	changes.Moved = detectMoves(old, current)
+	changes.PlaylistReplaced = !shared && len(old.Playlist) > 0 && len(current.Playlist) > 0
+	return changes
+}
+
+func (changes Changes) empty() bool {
+	return len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Renamed) == 0 && len(changes.Moved) == 0
+}
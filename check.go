@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// runChecks is the -check pre-flight: it validates the config, makes one
+// minimal request per playlist and verifies DirPath is writable, reporting
+// each result. It returns false if any check failed.
+func runChecks(w io.Writer) bool {
+	ok := true
+	report := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL %s: %v\n", name, err)
+		} else {
+			fmt.Fprintf(w, "PASS %s\n", name)
+		}
+	}
+
+	config, err := loadConfig()
+	report("config", err)
+	if err != nil {
+		return false
+	}
+
+	client, err := newAPIClient(config)
+	report("credentials", err)
+	if err == nil && config.SearchQuery != "" {
+		_, err := fetchSearchPage(client, config.SearchQuery, "", 1)
+		report(config.sourceName(), err)
+	} else if err == nil {
+		playlists := config.Playlists
+		if len(playlists) == 0 {
+			playlists = []PlaylistConfig{{PlaylistId: config.PlaylistId}}
+		}
+		for _, playlist := range playlists {
+			_, err := fetchPlaylistItems(client, playlist.PlaylistId, "", 1)
+			report("playlist "+playlist.name(), err)
+		}
+	}
+
+	report("dirPath "+config.DirPath, checkWritable(config.DirPath))
+	return ok
+}
+
+func checkWritable(dirPath string) error {
+	file, err := os.CreateTemp(dirPath, ".check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
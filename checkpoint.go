@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const checkpointFileName = ".playlist_machine.checkpoint"
+
+// checkpoint records which playlists of a multi-playlist run completed, so a
+// run restarted after a crash or timeout skips them. It is removed once a run
+// has processed every playlist, even when some failed.
+type checkpoint struct {
+	mu        sync.Mutex
+	config    *Config
+	Completed map[string]time.Time `json:"completed"`
+}
+
+// loadCheckpoint reads the checkpoint of an interrupted run, if any. It
+// returns nil when CheckpointMaxAge is unset.
+func loadCheckpoint(config *Config) *checkpoint {
+	if config.CheckpointMaxAge == 0 {
+		return nil
+	}
+	cp := &checkpoint{config: config, Completed: make(map[string]time.Time)}
+	data, err := os.ReadFile(filepath.Join(config.DirPath, checkpointFileName))
+	if err == nil {
+		if err := json.Unmarshal(data, cp); err != nil {
+			log.Printf("WARNING: ignoring unreadable checkpoint: %v", err)
+			cp.Completed = make(map[string]time.Time)
+		}
+	}
+	return cp
+}
+
+// done reports whether the named playlist completed within CheckpointMaxAge.
+func (cp *checkpoint) done(name string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	completedAt, found := cp.Completed[name]
+	return found && time.Since(completedAt) < time.Duration(cp.config.CheckpointMaxAge)
+}
+
+func (cp *checkpoint) complete(name string) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.Completed[name] = time.Now()
+	err := createFile(filepath.Join(cp.config.DirPath, checkpointFileName), cp.config.FileMode, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(cp)
+	})
+	if err != nil {
+		log.Printf("WARNING: error writing checkpoint: %v", err)
+	}
+}
+
+func (cp *checkpoint) clear() {
+	if cp != nil {
+		os.Remove(filepath.Join(cp.config.DirPath, checkpointFileName))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointClearedDespiteFailingPlaylist(t *testing.T) {
+	api := &fakeAPI{pages: []PlaylistItemsResponse{replayPage(testPlaylist(2).Playlist)}, missingPlaylists: []string{"GONE"}}
+	serveFakeAPI(t, api)
+	config := testConfig(t, map[string]any{
+		"playlistId":       "",
+		"playlists":        []map[string]string{{"playlistId": "P1"}, {"playlistId": "GONE"}},
+		"checkpointMaxAge": "1h",
+	})
+
+	for run := range 2 {
+		result, err := Run(config, RunOptions{})
+		if err == nil {
+			t.Fatalf("run %d: want the error of playlist GONE", run+1)
+		}
+		if result.Playlists[0].Skipped || result.Playlists[0].Error != "" {
+			t.Errorf("run %d: playlist P1 skipped %v with error %q, want it fetched", run+1, result.Playlists[0].Skipped, result.Playlists[0].Error)
+		}
+		if _, err := os.Stat(filepath.Join(config.DirPath, checkpointFileName)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("run %d left the checkpoint behind: %v", run+1, err)
+		}
+	}
+}
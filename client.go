@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// apiBaseURL is a variable so tests can point the client at a local server.
+var apiBaseURL = "https://www.googleapis.com/youtube/v3"
+
+const defaultMaxResponseBytes = 10 << 20
+
+type apiClient struct {
+	apiKey string
+	auth   tokenSource
+	retry  retryPolicy
+	http   *http.Client
+	// maxResponseBytes caps the size of a response body, see
+	// Config.MaxResponseBytes.
+	maxResponseBytes int64
+	// ctx ends when the run times out, which also cancels requests in
+	// flight and backoff between retries.
+	ctx context.Context
+	// replayDir, when set, serves playlistItems pages from saved responses
+	// instead of the network.
+	replayDir string
+	// saveDir, when set, keeps the raw playlistItems responses under the
+	// names replayDir reads.
+	saveDir string
+	// warnSchemaDrift checks every response against the types it decodes
+	// into, see Config.WarnAPISchemaDrift.
+	warnSchemaDrift bool
+	schemaMu        sync.Mutex
+	schemaWarnings  map[string]bool
+	calls           atomic.Int64
+}
+
+func newAPIClient(config *Config) (*apiClient, error) {
+	client := &apiClient{
+		apiKey: config.ApiKey,
+		retry:  newRetryPolicy(config),
+		http:   &http.Client{Timeout: time.Duration(config.RequestTimeout), Transport: newTransport(config)},
+		ctx:    context.Background(),
+
+		maxResponseBytes: config.MaxResponseBytes,
+		warnSchemaDrift:  config.WarnAPISchemaDrift,
+	}
+	if config.OAuthRefreshToken != "" {
+		client.auth = &userCredentials{
+			clientId:     config.OAuthClientId,
+			clientSecret: config.OAuthClientSecret,
+			refreshToken: config.OAuthRefreshToken,
+		}
+	} else if config.ServiceAccountKeyFile != "" {
+		account, err := newServiceAccount(config.ServiceAccountKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading service account: %w", err)
+		}
+		client.auth = account
+	}
+	return client, nil
+}
+
+// credential identifies the API key or account requests are made with, for
+// the Credential of written playlists.
+func (client *apiClient) credential() string {
+	if client.auth != nil {
+		return client.auth.identity()
+	}
+	if client.apiKey != "" {
+		return "apiKey:" + fingerprint(client.apiKey)
+	}
+	return ""
+}
+
+// newTransport pools connections to the API as tuned in the config. Requests
+// to Google go over HTTP/2, where one connection carries them all.
+func newTransport(config *Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	}
+	if config.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(config.IdleConnTimeout)
+	}
+	return transport
+}
+
+type retryPolicy struct {
+	maxRetries  int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	factor      float64
+	statusCodes []int
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+	http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+}
+
+func newRetryPolicy(config *Config) retryPolicy {
+	return retryPolicy{
+		maxRetries:  config.MaxRetries,
+		baseDelay:   time.Duration(config.RetryBaseDelay),
+		maxDelay:    time.Duration(config.RetryMaxDelay),
+		factor:      config.RetryFactor,
+		statusCodes: config.RetryStatusCodes,
+	}
+}
+
+func (policy retryPolicy) delay(attempt int) time.Duration {
+	delay := float64(policy.baseDelay) * math.Pow(policy.factor, float64(attempt))
+	if delay > float64(policy.maxDelay) {
+		return policy.maxDelay
+	}
+	return time.Duration(delay)
+}
+
+var (
+	ErrPlaylistPrivate  = errors.New("playlist is private")
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	ErrQuotaExceeded    = errors.New("API quota exceeded")
+	ErrResponseTooLarge = errors.New("response body exceeds maxResponseBytes")
+
+	errAuthenticating = errors.New("error authenticating")
+)
+
+type statusError struct {
+	statusCode int
+	reason     string
+	retryAfter time.Duration
+}
+
+// apiErrorResponse is the error body Google APIs return with non-200 codes.
+type apiErrorResponse struct {
+	Error struct {
+		Errors []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+func (err *statusError) Error() string {
+	if err.reason != "" {
+		return fmt.Sprintf("API call failed, status code: %d (%s)", err.statusCode, err.reason)
+	}
+	return fmt.Sprintf("API call failed, status code: %d", err.statusCode)
+}
+
+// Unwrap maps the error reason onto a sentinel, which tells a 403 for a
+// private playlist apart from a 403 for an exhausted quota.
+func (err *statusError) Unwrap() error {
+	switch err.reason {
+	case "playlistItemsNotAccessible", "playlistForbidden":
+		return ErrPlaylistPrivate
+	case "playlistNotFound":
+		return ErrPlaylistNotFound
+	case "quotaExceeded", "dailyLimitExceeded":
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (policy retryPolicy) retryable(err error) bool {
+	// The token exchange was already retried.
+	if errors.Is(err, ErrResponseTooLarge) || errors.Is(err, errAuthenticating) {
+		return false
+	}
+	var status *statusError
+	if !errors.As(err, &status) {
+		// Transport errors such as timeouts and resets are worth retrying.
+		return true
+	}
+	return slices.Contains(policy.statusCodes, status.statusCode)
+}
+
+func (client *apiClient) getJSON(endpoint string, params url.Values, v any) error {
+	if client.replayDir != "" {
+		return client.replay(endpoint, params, v)
+	}
+	if client.apiKey != "" {
+		params.Set("key", client.apiKey)
+	}
+	url := fmt.Sprintf("%s/%s?%s", apiBaseURL, endpoint, params.Encode())
+
+	var data []byte
+	err := client.retrying(endpoint, func() (err error) {
+		data, err = client.get(url, v)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if client.warnSchemaDrift {
+		client.checkSchema(endpoint, data, v)
+	}
+	if client.saveDir != "" && endpoint == "playlistItems" {
+		return client.save(params.Get("pageToken"), data)
+	}
+	return nil
+}
+
+// retrying calls request until it succeeds, fails for good or the retries
+// run out, backing off between attempts.
+func (client *apiClient) retrying(endpoint string, request func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := request()
+		if err == nil || attempt >= client.retry.maxRetries || !client.retry.retryable(err) || client.ctx.Err() != nil {
+			return err
+		}
+
+		delay := client.retry.delay(attempt)
+		var status *statusError
+		if errors.As(err, &status) && status.statusCode == http.StatusTooManyRequests && status.retryAfter > 0 {
+			delay = min(status.retryAfter, client.retry.maxDelay)
+		}
+		log.Printf("Request to %s failed (%v), retrying in %s", endpoint, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-client.ctx.Done():
+			return fmt.Errorf("%w, last error: %v", client.ctx.Err(), err)
+		}
+	}
+}
+
+// get fetches url into v and returns the raw response body.
+func (client *apiClient) get(url string, v any) ([]byte, error) {
+	req, err := http.NewRequestWithContext(client.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client.auth != nil {
+		token, err := client.auth.token(client)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errAuthenticating, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client.calls.Add(1)
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := client.readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := &statusError{statusCode: resp.StatusCode}
+		var body apiErrorResponse
+		if json.Unmarshal(data, &body) == nil && len(body.Error.Errors) > 0 {
+			err.reason = body.Error.Errors[0].Reason
+		}
+		if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+			err.retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, err
+	}
+
+	return data, json.Unmarshal(data, v)
+}
+
+// readBody reads a response body of at most maxResponseBytes, zero meaning no
+// limit.
+func (client *apiClient) readBody(body io.Reader) ([]byte, error) {
+	if client.maxResponseBytes <= 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, client.maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > client.maxResponseBytes {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrResponseTooLarge, client.maxResponseBytes)
+	}
+	return data, nil
+}
+
+// replayFileName names the saved response for a playlistItems page: the
+// first page is first.json and later ones are <pageToken>.json.
+func replayFileName(pageToken string) string {
+	if pageToken == "" {
+		return "first.json"
+	}
+	return pageToken + ".json"
+}
+
+// save writes a raw playlistItems response to saveDir for a later replay.
+func (client *apiClient) save(pageToken string, data []byte) error {
+	if err := os.WriteFile(filepath.Join(client.saveDir, replayFileName(pageToken)), data, 0644); err != nil {
+		return fmt.Errorf("error saving raw response: %w", err)
+	}
+	return nil
+}
+
+func (client *apiClient) replay(endpoint string, params url.Values, v any) error {
+	if endpoint != "playlistItems" {
+		return fmt.Errorf("%s is not available in replay mode", endpoint)
+	}
+
+	data, err := os.ReadFile(filepath.Join(client.replayDir, replayFileName(params.Get("pageToken"))))
+	if err != nil {
+		return fmt.Errorf("error reading replayed response: %w", err)
+	}
+	if client.warnSchemaDrift {
+		client.checkSchema(endpoint, data, v)
+	}
+	return json.Unmarshal(data, v)
+}
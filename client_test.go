@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves pages of playlistItems, the playlist size and the videos
+// endpoint the way the YouTube API does, for the videos in pages.
+type fakeAPI struct {
+	pages []PlaylistItemsResponse
+	// missingPlaylists are the playlistIds answered with playlistNotFound.
+	missingPlaylists []string
+	// videosStatus, when set, is returned for every videos request.
+	videosStatus int
+	// videosDelay holds up every videos response, so requests overlap.
+	videosDelay time.Duration
+	videosCalls atomic.Int64
+	// videosInFlight counts the videos requests being served and
+	// maxVideosInFlight the most served at once.
+	videosInFlight, maxVideosInFlight atomic.Int64
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "playlistItems":
+		if slices.Contains(api.missingPlaylists, query.Get("playlistId")) {
+			http.Error(w, `{"error":{"errors":[{"reason":"playlistNotFound"}]}}`, http.StatusNotFound)
+			return
+		}
+		page := 0
+		if token := query.Get("pageToken"); token != "" {
+			page, _ = strconv.Atoi(strings.TrimPrefix(token, "page"))
+		}
+		if page >= len(api.pages) {
+			http.Error(w, "unknown pageToken", http.StatusBadRequest)
+			return
+		}
+		response := api.pages[page]
+		if page+1 < len(api.pages) {
+			response.NextPageToken = fmt.Sprintf("page%d", page+1)
+		}
+		json.NewEncoder(w).Encode(response)
+	case "videos":
+		api.videosCalls.Add(1)
+		inFlight := api.videosInFlight.Add(1)
+		defer api.videosInFlight.Add(-1)
+		for {
+			most := api.maxVideosInFlight.Load()
+			if inFlight <= most || api.maxVideosInFlight.CompareAndSwap(most, inFlight) {
+				break
+			}
+		}
+		time.Sleep(api.videosDelay)
+		if api.videosStatus != 0 {
+			http.Error(w, `{"error":{"errors":[{"reason":"backendError"}]}}`, api.videosStatus)
+			return
+		}
+		var response VideosResponse
+		for _, videoId := range strings.Split(query.Get("id"), ",") {
+			var details VideoDetails
+			details.Id = videoId
+			details.Status.PrivacyStatus = "public"
+			details.ContentDetails.Duration = "PT30S"
+			response.Items = append(response.Items, details)
+		}
+		json.NewEncoder(w).Encode(response)
+	case "playlists":
+		itemCount := 0
+		for _, page := range api.pages {
+			itemCount += len(page.Items)
+		}
+		fmt.Fprintf(w, `{"items":[{"contentDetails":{"itemCount":%d}}]}`, itemCount)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveFakeAPI points the client at api for the rest of the test.
+func serveFakeAPI(t testing.TB, api http.Handler) *httptest.Server {
+	server := httptest.NewServer(api)
+	baseURL := apiBaseURL
+	apiBaseURL = server.URL
+	t.Cleanup(func() {
+		apiBaseURL = baseURL
+		server.Close()
+	})
+	return server
+}
+
+// BenchmarkTransportReuse fetches many playlists with one client over TLS,
+// reporting the connections opened, which should stay at one as HTTP/2
+// carries every request.
+func BenchmarkTransportReuse(b *testing.B) {
+	const playlistCount = 100
+	api := &fakeAPI{pages: []PlaylistItemsResponse{replayPage(testPlaylist(5).Playlist)}}
+	var connections, http1Requests atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http1Requests.Add(1)
+		}
+		api.ServeHTTP(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+	baseURL := apiBaseURL
+	apiBaseURL = server.URL
+	defer func() { apiBaseURL = baseURL }()
+
+	config := testConfig(b, nil)
+	client, err := newAPIClient(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	client.http.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	b.ResetTimer()
+	for range b.N {
+		for range playlistCount {
+			if _, err := fetchPlaylist(config, client); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.StopTimer()
+
+	if n := http1Requests.Load(); n > 0 {
+		b.Errorf("%d requests did not use HTTP/2", n)
+	}
+	b.ReportMetric(float64(connections.Load()), "connections")
+	b.ReportMetric(float64(client.calls.Load())/float64(b.N), "requests/op")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// configComments document the config keys in the template -init writes.
+// Keys documented with the one before them, such as retryMaxDelay, have none.
+var configComments = map[string]string{
+	"apiKey":                  "YouTube Data API key. The YOUTUBE_API_KEY environment variable and apiKeyFile win over it.",
+	"playlistId":              "ID of the playlist to track, or WL, HL and LL for the personal ones.",
+	"dirPath":                 "Directory of the playlist, diff and history files. Defaults to the working directory.",
+	"diffFileName":            "File in dirPath with the changes the latest run found.",
+	"playlistFileName":        "File in dirPath with the playlist as last fetched.",
+	"keepHistory":             "Keep each replaced playlist and diff as a timestamped snapshot.",
+	"alwaysWriteDiff":         "Write the diff on every run, even when it is empty.",
+	"serviceAccountKeyFile":   "Google service account JSON key to authorize with instead of apiKey.",
+	"baselineFile":            "File in dirPath diffed against instead of playlistFileName, \"-\" for stdin.",
+	"oauthClientId":           "OAuth client and refresh token of a YouTube user, needed for Liked videos (LL).",
+	"fetchMetadata":           "Look every video up to record its availability, one API call per 50 videos.",
+	"regionCode":              "ISO 3166-1 country that region restrictions are checked against.",
+	"shrinkGuard":             "Largest drop in video count, in percent, accepted without -force. 0 disables it.",
+	"incrementalDiff":         "Leave out changes the previous diff already reported.",
+	"maxRetries":              "Retries of a failed request, backing off from retryBaseDelay by retryFactor up to retryMaxDelay.",
+	"prettyJSON":              "Indent the JSON files with indentString.",
+	"lockMode":                "\"fail\" or \"wait\" when another run holds the lock.",
+	"sortBy":                  "\"none\" keeps API order, or \"title\", \"publishedAt\", \"uploadedAt\" or \"position\".",
+	"outputFormats":           "Extra formats written when the playlist changes, see -list-formats.",
+	"formatExtensions":        "Extension per output format, such as {\"urls\": \".urls.txt\"}.",
+	"apiKeyFile":              "File the API key is read from.",
+	"playlists":               "Several playlists tracked in one run, replacing playlistId.",
+	"playlistSubdirectories":  "Keep each of playlists in its own directory under dirPath.",
+	"jsonNaming":              "\"camelCase\" or \"snake_case\" keys in the JSON of outputFormats.",
+	"concurrency":             "How many of playlists are processed at once. 0 means 1.",
+	"interPlaylistDelay":      "Pause before each playlist after the first, such as \"2s\".",
+	"deletedTitles":           "Titles the API gives deleted videos, in the language of its responses.",
+	"adaptivePageSize":        "Shrink the page size while requests time out.",
+	"ignoreTitleChanges":      "Only report added and removed videos, never renames.",
+	"templateFile":            "Go text/template rendered by the template output format.",
+	"shorts":                  "\"include\", \"exclude\" or \"separate\" Shorts into shortsFileName. Needs fetchMetadata.",
+	"encryptionKeyFile":       "32 byte key, hex or base64, encrypting the playlist, diff and history files.",
+	"fileMode":                "Octal permission of the files written, such as \"0600\".",
+	"requestTimeout":          "Timeout of each API request and of the whole run. 0 disables either.",
+	"trackDescriptions":       "Store the note of each playlist item and report edits to it.",
+	"trackThumbnails":         "Report changes to the playlist cover images.",
+	"renotifyUnchanged":       "Publish a diff again when it is the same as the stored one.",
+	"recoverDeletedTitles":    "Replace the titles of deleted videos in the diff with the last known ones.",
+	"legacyDiffFormat":        "Write diffs as a flat list of videos rather than by category.",
+	"warnAPISchemaDrift":      "Warn when API responses gain or lose fields.",
+	"searchQuery":             "Track the top searchMaxResults results of a search instead of playlistId, at 100 quota units a page.",
+	"earlyStopOnKnown":        "Stop paginating at the first page of known videos, for append-only playlists.",
+	"emptyPageRetries":        "Retries of an empty page while the API reports more items.",
+	"missingTimeBehavior":     "\"zero\", \"skip\" or \"error\" for items without a valid publishedAt.",
+	"reportLocale":            "Locale of the numbers in countsFile and templates, such as \"de\".",
+	"syslog":                  "Log a line per changed playlist to syslog at syslogPriority, tagged syslogTag.",
+	"runName":                 "Label of this setup in log lines and the summary.",
+	"maxResponseBytes":        "Largest API response body accepted.",
+	"retryStatusCodes":        "HTTP status codes worth retrying.",
+	"trackOnlyVideoIds":       "Keep only these videos of the playlist.",
+	"tolerateReadOnlyDirPath": "Warn instead of failing when dirPath is read-only. Needs publishBroker.",
+	"deduplicateOnWrite":      "Keep only the first entry of a video listed more than once.",
+	"checkpointMaxAge":        "Let a restarted multi-playlist run skip playlists completed this recently. 0 disables it.",
+	"publishBroker":           "\"redis\" to publish the changes to publishChannel at publishAddr.",
+	"removedFileName":         "File in dirPath with only the videos removed since the previous run.",
+	"indentString":            "Indentation of pretty JSON, spaces and tabs only.",
+	"notesFile":               "File with a note attached to the files the next run writes.",
+	"strmDir":                 "Directory receiving a .strm file per video for media servers.",
+	"replacementGuard":        "Refuse to overwrite a playlist none of whose videos are left, without -force.",
+	"updatedAtSource":         "\"now\" or \"latestAdded\" to stamp files with the latest AddedToPlaylistAt.",
+	"countsFile":              "CSV file in dirPath every run appends its video counts to.",
+	"diffNewVideos":           "Write the added videos as the diff when none were removed.",
+	"historyArchiveFileName":  "File in dirPath -archive gathers the history snapshots into.",
+	"maxIdleConns":            "Pool of idle API connections. 0 keeps Go's defaults.",
+	"storedFields":            "Video fields kept besides videoId, such as [\"title\"]. Empty keeps every field.",
+}
+
+// commented puts the comment of each top-level key of the indented JSON
+// object data on the line before it.
+func commented(data []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		key, isKey := strings.CutPrefix(line, `  "`)
+		if isKey {
+			key, _, _ = strings.Cut(key, `"`)
+			if comment := configComments[key]; comment != "" {
+				out.WriteString("  // " + comment + "\n")
+			}
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// withoutComments drops the // comments of a config file, outside strings
+// such as URLs, and keeps the line breaks that end them.
+func withoutComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
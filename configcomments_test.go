@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInitConfigTemplateLoads(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := initConfig(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(configFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "  // ") {
+		t.Errorf("template has no comments:\n%s", data)
+	}
+
+	settings, err := readConfigTree(configFileName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]any
+	if err := json.Unmarshal(settings, &config); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"lockMode": "fail", "sortBy": "none", "jsonNaming": "camelCase",
+		"missingTimeBehavior": "zero", "syslogPriority": "info", "updatedAtSource": "now"} {
+		if config[key] != want {
+			t.Errorf("template sets %s to %q, want its default %q", key, config[key], want)
+		}
+	}
+
+	// The template loads, comments and all, once it names a playlist.
+	named := strings.Replace(string(data), `"playlistId": ""`, `"playlistId": "P1"`, 1)
+	if err := os.WriteFile(configFileName, []byte(named), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(configFileName); err != nil {
+		t.Errorf("template does not load: %v", err)
+	}
+}
+
+func TestWithoutCommentsKeepsStrings(t *testing.T) {
+	data := "{\n  // the key\n  \"apiKeyFile\": \"https://example.com/a\\\"//b\", // trailing\n  \"runName\": \"x\"\n}"
+	var config struct {
+		ApiKeyFile string `json:"apiKeyFile"`
+		RunName    string `json:"runName"`
+	}
+	if err := json.Unmarshal(withoutComments([]byte(data)), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.ApiKeyFile != `https://example.com/a"//b` || config.RunName != "x" {
+		t.Errorf("read %+v", config)
+	}
+}
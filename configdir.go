@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// runConfigDir runs every *.json config in dir one after the other, so one
+// invocation can serve many unrelated setups. A config that fails to load or
+// run does not stop the others. Each config should set its own DirPath, as
+// they otherwise all default to the working directory and share its lock.
+// Files another config in dir includes are fragments, not configs, and are
+// not run on their own. It reports a line per config and returns false if
+// any failed.
+func runConfigDir(w io.Writer, dir string, options RunOptions, color bool) bool {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		fmt.Fprintf(w, "Error listing configs in %s: %v\n", dir, err)
+		return false
+	}
+	included := make(map[string]bool)
+	for _, fileName := range fileNames {
+		includedFiles(fileName, included)
+	}
+	fileNames = slices.DeleteFunc(fileNames, func(fileName string) bool {
+		path, err := filepath.Abs(fileName)
+		return err == nil && included[path]
+	})
+	if len(fileNames) == 0 {
+		fmt.Fprintf(w, "No configs found in %s\n", dir)
+		return false
+	}
+
+	type outcome struct {
+		name   string
+		result RunResult
+		err    error
+	}
+	var outcomes []outcome
+	for _, fileName := range fileNames {
+		name := filepath.Base(fileName)
+		config, err := loadConfigFile(fileName)
+		if err != nil {
+			outcomes = append(outcomes, outcome{name: name, err: err})
+			continue
+		}
+
+		if config.RunName != "" {
+			name = config.RunName + " (" + name + ")"
+		}
+		fmt.Fprintf(w, "Running %s\n", name)
+		config.setLogPrefix()
+		result, err := Run(config, options)
+		log.SetPrefix("")
+		printSummaries(w, result, color)
+		outcomes = append(outcomes, outcome{name, result, err})
+	}
+
+	ok := true
+	fmt.Fprintln(w, "\nConfigs:")
+	for _, outcome := range outcomes {
+		var added, removed int
+		for _, playlist := range outcome.result.Playlists {
+			added += playlist.Added
+			removed += playlist.Removed
+		}
+		if outcome.err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL %s: %v\n", outcome.name, outcome.err)
+			continue
+		}
+		fmt.Fprintf(w, "OK   %s: %d playlists, %d added, %d removed in %s with %d API calls\n",
+			outcome.name, len(outcome.result.Playlists), added, removed,
+			outcome.result.Duration.Round(time.Millisecond), outcome.result.APICalls)
+	}
+	return ok
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunConfigDirSkipsIncludedFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJSON(t, dir, "playlist.json", map[string]any{"apiKey": "key", "playlistId": "P1", "dirPath": t.TempDir(), "include": []string{"common.json"}})
+	writeTestJSON(t, dir, "common.json", map[string]any{"keepHistory": true})
+	replayDir := t.TempDir()
+	writeTestJSON(t, replayDir, replayFileName(""), replayPage(testPlaylist(2).Playlist))
+
+	var report strings.Builder
+	if !runConfigDir(&report, dir, RunOptions{ReplayDir: replayDir}, false) {
+		t.Errorf("a config failed:\n%s", &report)
+	}
+	if strings.Contains(report.String(), "common.json") {
+		t.Errorf("ran the included common.json as a config:\n%s", &report)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// appendCounts adds a row of the video count and the number of added and
+// removed videos to CountsFile, a CSV time series cheaper to graph than the
+// history snapshots. A new file starts with a header row.
+func (config Config) appendCounts(playlist *YoutubePlaylist, changes *Changes) {
+	if config.CountsFile == "" {
+		return
+	}
+	perm := os.FileMode(0666)
+	if config.FileMode != 0 {
+		perm = os.FileMode(config.FileMode)
+	}
+	filePath := filepath.Join(config.DirPath, config.CountsFile)
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		log.Printf("WARNING: error opening %s: %v", config.CountsFile, err)
+		return
+	}
+	defer file.Close()
+
+	locale, _ := findNumberLocale(config.ReportLocale)
+	w := csv.NewWriter(file)
+	w.Comma = locale.comma()
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		w.Write([]string{"timestamp", "count", "added", "removed"})
+	}
+	w.Write([]string{
+		playlist.UpdatedAt.Format(time.RFC3339),
+		locale.formatInt(len(playlist.Playlist)),
+		locale.formatInt(len(changes.Added)),
+		locale.formatInt(len(changes.Removed)),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("WARNING: error writing %s: %v", config.CountsFile, err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const crossMovesFileName = "cross_moves.json"
+
+// CrossMove is a video removed from one tracked playlist and added to
+// another in the same run.
+type CrossMove struct {
+	Video Video  `json:"video"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+func detectCrossMoves(playlists []PlaylistResult) []CrossMove {
+	removedFrom := make(map[string][]string)
+	for _, playlist := range playlists {
+		if playlist.Changes == nil {
+			continue
+		}
+		for _, video := range playlist.Changes.Removed {
+			removedFrom[video.VideoId] = append(removedFrom[video.VideoId], playlist.Name)
+		}
+	}
+
+	var moves []CrossMove
+	for _, playlist := range playlists {
+		if playlist.Changes == nil {
+			continue
+		}
+		for _, video := range playlist.Changes.Added {
+			for _, from := range removedFrom[video.VideoId] {
+				if from != playlist.Name {
+					moves = append(moves, CrossMove{Video: video, From: from, To: playlist.Name})
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// writeCrossMoves writes the videos that moved between playlists this run to
+// cross_moves.json in DirPath, or removes the file when none did.
+func (config Config) writeCrossMoves(moves []CrossMove) {
+	if len(moves) == 0 {
+		os.Remove(filepath.Join(config.DirPath, crossMovesFileName))
+		return
+	}
+	config.writeOutput(crossMovesFileName, "cross-playlist moves", func(w io.Writer) error {
+		return encodeJSON(w, moves, config.writeOptions())
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// encryptedMagic starts every encrypted file, so readers can tell them from
+// plain JSON written before encryption was enabled.
+var encryptedMagic = []byte("PLAYLIST-MACHINE-AESGCM-1\n")
+
+// readEncryptionKey loads a 32 byte AES-256 key stored hex or base64 encoded.
+func readEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	encoded := strings.TrimSpace(string(data))
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes, hex or base64 encoded")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// encrypting passes w to write, or with a key a buffer whose contents are
+// written to w encrypted once write returns.
+func encrypting(w io.Writer, key []byte, write func(w io.Writer) error) error {
+	if key == nil {
+		return write(w)
+	}
+
+	var plaintext bytes.Buffer
+	if err := write(&plaintext); err != nil {
+		return err
+	}
+	ciphertext, err := encrypt(key, plaintext.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(ciphertext)
+	return err
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("file is encrypted but no encryptionKeyFile is configured")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(encryptedMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting file: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+)
+
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandEnv expands $VAR and ${VAR} in every string of the config, including
+// those in lists and Playlists, so one file serves several environments.
+// References to unset variables are left as they are.
+func (config *Config) expandEnv() {
+	expandValue(reflect.ValueOf(config).Elem())
+}
+
+func expandValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(envReferencePattern.ReplaceAllStringFunc(v.String(), func(reference string) string {
+			match := envReferencePattern.FindStringSubmatch(reference)
+			if value, found := os.LookupEnv(match[1] + match[2]); found {
+				return value
+			}
+			return reference
+		}))
+	case reflect.Slice:
+		for i := range v.Len() {
+			expandValue(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				expandValue(v.Field(i))
+			}
+		}
+	}
+}
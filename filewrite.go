@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	fileWriteAttempts   = 3
+	fileWriteRetryDelay = 500 * time.Millisecond
+)
+
+// transientFileError reports errors a network filesystem may recover from,
+// such as a stale NFS handle.
+func transientFileError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EINTR)
+}
+
+// FileMode reads file permissions from config as octal strings such as
+// "0600", with "" meaning the default.
+type FileMode os.FileMode
+
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	if m == 0 {
+		return json.Marshal("")
+	}
+	return json.Marshal(fmt.Sprintf("%04o", uint32(m)))
+}
+
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("file mode must be an octal string such as \"0600\": %w", err)
+	}
+	if s == "" {
+		*m = 0
+		return nil
+	}
+	parsed, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || parsed > 0777 {
+		return fmt.Errorf("file mode must be an octal string such as \"0600\", got %q", s)
+	}
+	*m = FileMode(parsed)
+	return nil
+}
+
+// readOnlyError reports errors from writing to a directory that cannot be
+// written to.
+func readOnlyError(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS)
+}
+
+// createFile writes a whole file, rewriting it from scratch a few times if it
+// fails transiently, and returns the last error. A non-zero mode is also
+// applied to files that already exist.
+func createFile(filePath string, mode FileMode, write func(w io.Writer) error) error {
+	var err error
+	for attempt := 1; attempt <= fileWriteAttempts; attempt++ {
+		err = createFileOnce(filePath, mode, write)
+		if err == nil || !transientFileError(err) {
+			return err
+		}
+		if attempt < fileWriteAttempts {
+			log.Printf("Writing %s failed (%v), retrying", filePath, err)
+			time.Sleep(fileWriteRetryDelay)
+		}
+	}
+	return err
+}
+
+func createFileOnce(filePath string, mode FileMode, write func(w io.Writer) error) error {
+	perm := os.FileMode(0666)
+	if mode != 0 {
+		perm = os.FileMode(mode)
+	}
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if mode != 0 {
+		if err := file.Chmod(perm); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// backupFile copies fileName in DirPath to a .bak file next to it.
+func (config Config) backupFile(fileName string) error {
+	path := filepath.Join(config.DirPath, fileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return createFile(path+".bak", config.FileMode, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var videoIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// videoIdFromURL extracts the VideoId of a watch?v=, youtu.be, shorts, embed
+// or live URL, or of a bare VideoId.
+func videoIdFromURL(line string) (string, bool) {
+	if videoIdPattern.MatchString(line) {
+		return line, true
+	}
+	parsed, err := url.Parse(line)
+	if err != nil {
+		return "", false
+	}
+
+	var videoId string
+	host := strings.TrimPrefix(parsed.Hostname(), "www.")
+	switch {
+	case host == "youtu.be":
+		videoId = strings.Trim(parsed.Path, "/")
+	case host == "youtube.com" || strings.HasSuffix(host, ".youtube.com"):
+		videoId = parsed.Query().Get("v")
+		for _, prefix := range []string{"/shorts/", "/embed/", "/live/"} {
+			if rest, found := strings.CutPrefix(parsed.Path, prefix); found {
+				videoId = strings.Trim(rest, "/")
+			}
+		}
+	}
+	return videoId, videoIdPattern.MatchString(videoId)
+}
+
+// readVideoList reads the videos of an M3U playlist or a list of URLs or
+// VideoIds, one per line, in order and without duplicates. Titles come from
+// the #EXTINF lines of M3U playlists.
+func readVideoList(r io.Reader) ([]Video, error) {
+	var videos []Video
+	seen := make(map[string]bool)
+	title := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if info, found := strings.CutPrefix(line, "#EXTINF:"); found {
+			if _, name, found := strings.Cut(info, ","); found {
+				title = strings.TrimSpace(name)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		videoId, ok := videoIdFromURL(line)
+		if !ok {
+			log.Printf("WARNING: skipping %q, which is not a YouTube video URL", line)
+		} else if !seen[videoId] {
+			seen[videoId] = true
+			videos = append(videos, Video{Title: title, VideoId: videoId, Position: len(videos)})
+		}
+		title = ""
+	}
+	return videos, scanner.Err()
+}
+
+type VideoSnippetsResponse struct {
+	Items []struct {
+		Id      string `json:"id"`
+		Snippet struct {
+			Title        string `json:"title"`
+			PublishedAt  string `json:"publishedAt"`
+			ChannelId    string `json:"channelId"`
+			ChannelTitle string `json:"channelTitle"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
+
+// fillFromAPI sets the title, channel and upload time of videos from the
+// videos endpoint. Videos the API does not return keep what they had.
+func fillFromAPI(client *apiClient, videos []Video) error {
+	for start := 0; start < len(videos); start += videosBatchSize {
+		batch := videos[start:min(start+videosBatchSize, len(videos))]
+		videoIds := make([]string, len(batch))
+		for i, video := range batch {
+			videoIds[i] = video.VideoId
+		}
+
+		params := url.Values{}
+		params.Set("part", "snippet")
+		params.Set("maxResults", "50")
+		params.Set("id", strings.Join(videoIds, ","))
+		var response VideoSnippetsResponse
+		if err := client.getJSON("videos", params, &response); err != nil {
+			return err
+		}
+
+		for _, item := range response.Items {
+			for i := range batch {
+				if batch[i].VideoId != item.Id {
+					continue
+				}
+				batch[i].Title = item.Snippet.Title
+				batch[i].ChannelId = item.Snippet.ChannelId
+				batch[i].ChannelTitle = item.Snippet.ChannelTitle
+				if uploadedAt, err := time.Parse(time.RFC3339, item.Snippet.PublishedAt); err == nil {
+					batch[i].UploadedAt = uploadedAt
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// importVideoList writes the videos of an M3U or URL list file as
+// PlaylistFileName, to diff the first run against. Titles are fetched when
+// credentials are configured. An existing playlist file is only replaced
+// with force.
+func importVideoList(config *Config, fileName string, force bool) error {
+	if len(config.Playlists) > 0 {
+		return errors.New("import needs a single playlistId, not playlists")
+	}
+	lock, err := acquireLock(config)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	if _, err := os.Stat(filepath.Join(config.DirPath, config.PlaylistFileName)); !errors.Is(err, fs.ErrNotExist) && !force {
+		return fmt.Errorf("%s already exists, rerun with -force to replace it", config.PlaylistFileName)
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	videos, err := readVideoList(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", fileName, err)
+	}
+
+	if config.ApiKey != "" || config.OAuthRefreshToken != "" || config.ServiceAccountKeyFile != "" {
+		client, err := newAPIClient(config)
+		if err != nil {
+			return fmt.Errorf("error creating API client: %w", err)
+		}
+		if err := fillFromAPI(client, videos); err != nil {
+			log.Printf("WARNING: error fetching video titles, importing without them: %v", err)
+		}
+	}
+
+	config.writeFile(newPlaylist(videos), config.PlaylistFileName)
+	fmt.Printf("Imported %d videos from %s\n", len(videos), fileName)
+	return nil
+}
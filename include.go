@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readConfigTree reads a config file merged with the files in its "include"
+// list, relative to its directory. The file comes first and each include
+// overrides what came before it: objects are merged key by key, anything else
+// is replaced. Lines may end in // comments, as in the template -init
+// writes. including holds the files that led here, to catch cycles.
+func readConfigTree(fileName string, including []string) ([]byte, error) {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range including {
+		if parent == path {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(including, path), " -> "))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	data = withoutComments(data)
+	var config map[string]any
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config file %s: %w", fileName, err)
+	}
+	includes, _ := config["include"].([]any)
+	if len(includes) == 0 {
+		return data, nil
+	}
+	delete(config, "include")
+
+	for _, include := range includes {
+		includeName, ok := include.(string)
+		if !ok {
+			return nil, fmt.Errorf("include in %s must list file names", fileName)
+		}
+		if !filepath.IsAbs(includeName) {
+			includeName = filepath.Join(filepath.Dir(path), includeName)
+		}
+		included, err := readConfigTree(includeName, append(including, path))
+		if err != nil {
+			return nil, err
+		}
+		var fragment map[string]any
+		if err := json.Unmarshal(included, &fragment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config file %s: %w", includeName, err)
+		}
+		delete(fragment, "include")
+		mergeConfig(config, fragment)
+	}
+	return json.Marshal(config)
+}
+
+// includedFiles adds the absolute paths of the files fileName includes, and
+// those they include in turn, to included. Files that cannot be read are
+// skipped, loading the config reports them.
+func includedFiles(fileName string, included map[string]bool) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	var config struct {
+		Include []string `json:"include"`
+	}
+	if json.Unmarshal(withoutComments(data), &config) != nil {
+		return
+	}
+	for _, includeName := range config.Include {
+		if !filepath.IsAbs(includeName) {
+			includeName = filepath.Join(filepath.Dir(fileName), includeName)
+		}
+		path, err := filepath.Abs(includeName)
+		if err != nil || included[path] {
+			continue
+		}
+		included[path] = true
+		includedFiles(path, included)
+	}
+}
+
+func mergeConfig(dst, src map[string]any) {
+	for key, value := range src {
+		srcObject, srcIsObject := value.(map[string]any)
+		dstObject, dstIsObject := dst[key].(map[string]any)
+		if srcIsObject && dstIsObject {
+			mergeConfig(dstObject, srcObject)
+		} else {
+			dst[key] = value
+		}
+	}
+}
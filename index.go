@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const indexFileName = "index.json"
+
+// IndexEntry summarizes one tracked playlist for index.json, from its files
+// as they are after the run. LastDiffSize counts the videos in every
+// category of diff.json.
+type IndexEntry struct {
+	Name         string    `json:"name"`
+	PlaylistId   string    `json:"playlistId"`
+	VideoCount   int       `json:"videoCount"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	LastDiffSize int       `json:"lastDiffSize"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func (config Config) indexEntries(results []PlaylistResult) []IndexEntry {
+	entries := make([]IndexEntry, len(config.Playlists))
+	for i, playlist := range config.Playlists {
+		entries[i] = IndexEntry{Name: playlist.name(), PlaylistId: playlist.PlaylistId, Error: results[i].Error}
+		playlistConfig, err := config.forPlaylist(playlist)
+		if err != nil {
+			continue
+		}
+		if stored, err := readPlaylistFromFile(*playlistConfig, playlistConfig.PlaylistFileName); err == nil {
+			entries[i].VideoCount = len(stored.Playlist)
+			entries[i].UpdatedAt = stored.UpdatedAt
+		}
+		if diff, err := readPlaylistFromFile(*playlistConfig, playlistConfig.DiffFileName); err == nil {
+			entries[i].LastDiffSize = diff.size()
+		}
+	}
+	return entries
+}
+
+// writeIndex writes index.json in DirPath through a temporary file renamed
+// into place, so readers never see a partial index.
+func (config Config) writeIndex(results []PlaylistResult) {
+	entries := config.indexEntries(results)
+	filePath := filepath.Join(config.DirPath, indexFileName)
+	err := createFile(filePath+".tmp", config.FileMode, func(w io.Writer) error {
+		return encodeJSON(w, entries, config.writeOptions())
+	})
+	if err == nil {
+		err = os.Rename(filePath+".tmp", filePath)
+	}
+	if err != nil {
+		log.Printf("WARNING: error writing %s: %v", indexFileName, err)
+		return
+	}
+	fmt.Println("Index data written to", filePath)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberLocale is how a ReportLocale writes counts and durations. The zero
+// value is the machine-readable format: plain integers and ISO 8601
+// durations, in comma-separated CSV.
+type numberLocale struct {
+	// group separates thousands, leaving integers plain when empty.
+	group string
+	// csvComma separates CSV fields, since spreadsheets of locales with a
+	// decimal comma expect semicolons.
+	csvComma rune
+	// clockDurations writes durations as h:mm:ss rather than ISO 8601.
+	clockDurations bool
+}
+
+var numberLocales = map[string]numberLocale{
+	"en": {group: ",", csvComma: ',', clockDurations: true},
+	"de": {group: ".", csvComma: ';', clockDurations: true},
+	"es": {group: ".", csvComma: ';', clockDurations: true},
+	"fr": {group: " ", csvComma: ';', clockDurations: true},
+	"it": {group: ".", csvComma: ';', clockDurations: true},
+	"nl": {group: ".", csvComma: ';', clockDurations: true},
+}
+
+func findNumberLocale(name string) (numberLocale, error) {
+	if name == "" {
+		return numberLocale{}, nil
+	}
+	locale, found := numberLocales[name]
+	if !found {
+		return numberLocale{}, fmt.Errorf("unknown reportLocale %q", name)
+	}
+	return locale, nil
+}
+
+func (locale numberLocale) comma() rune {
+	if locale.csvComma == 0 {
+		return ','
+	}
+	return locale.csvComma
+}
+
+func (locale numberLocale) formatInt(n int) string {
+	digits := strconv.Itoa(n)
+	if locale.group == "" {
+		return digits
+	}
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(locale.group)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String()
+}
+
+// formatDuration writes an ISO 8601 duration from the API, such as
+// "PT1H2M3S", as "1:02:03" for clock durations. Invalid durations are kept as
+// they are.
+func (locale numberLocale) formatDuration(duration string) string {
+	parsed, err := parseISODuration(duration)
+	if !locale.clockDurations || err != nil {
+		return duration
+	}
+	seconds := int(parsed / time.Second)
+	hours, minutes := seconds/3600, seconds/60%60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds%60)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	lockFileName = ".playlist_machine.lock"
+	// A lock older than this is assumed stale even if its pid is alive,
+	// since the pid may have been reused after a crash.
+	lockMaxAge       = 24 * time.Hour
+	lockPollInterval = 5 * time.Second
+)
+
+var ErrLocked = errors.New("another run holds the lock")
+
+type runLock struct {
+	path string
+}
+
+type lockInfo struct {
+	Pid       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// acquireLock takes the run lock in config.DirPath. With LockMode "wait" it
+// polls until the lock is released, otherwise a held lock fails the run.
+func acquireLock(config *Config) (*runLock, error) {
+	path := filepath.Join(config.DirPath, lockFileName)
+	for {
+		err := tryLock(path)
+		if err == nil {
+			return &runLock{path: path}, nil
+		}
+		if config.TolerateReadOnlyDirPath && readOnlyError(err) {
+			log.Printf("WARNING: running without a lock, %s cannot be created: %v", path, err)
+			return &runLock{}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("error creating lock file: %w", err)
+		}
+
+		held, statErr := os.Stat(path)
+		if statErr != nil {
+			continue
+		}
+		info, readErr := readLock(path)
+		// A lock just created may not have its pid written yet.
+		unreadable := readErr != nil && time.Since(held.ModTime()) > lockPollInterval
+		if unreadable || (readErr == nil && info.stale()) {
+			log.Printf("Removing stale lock %s", path)
+			removeStaleLock(path, held)
+			continue
+		}
+		if config.LockMode != "wait" {
+			return nil, fmt.Errorf("%w: pid %d since %s (%s)", ErrLocked, info.Pid, info.StartedAt.Format(time.RFC3339), path)
+		}
+		log.Printf("Waiting for pid %d to release %s", info.Pid, path)
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// removeStaleLock removes the lock file at path if it is still stale, the
+// file last seen there. It is moved aside first, and put back when another
+// run replaced it with its own lock since, so two runs finding the same
+// stale lock cannot remove each other's.
+func removeStaleLock(path string, stale fs.FileInfo) {
+	moved := fmt.Sprintf("%s.%d.stale", path, os.Getpid())
+	if err := os.Rename(path, moved); err != nil {
+		return
+	}
+	// Inodes are reused, so the lock is only the same if unmodified too.
+	info, err := os.Stat(moved)
+	if err == nil && !(os.SameFile(stale, info) && info.ModTime().Equal(stale.ModTime()) && info.Size() == stale.Size()) {
+		os.Link(moved, path)
+	}
+	os.Remove(moved)
+}
+
+func tryLock(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(lockInfo{Pid: os.Getpid(), StartedAt: time.Now()})
+}
+
+func readLock(path string) (lockInfo, error) {
+	var info lockInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+func (info lockInfo) stale() bool {
+	if time.Since(info.StartedAt) > lockMaxAge {
+		return true
+	}
+	process, err := os.FindProcess(info.Pid)
+	if err != nil {
+		return true
+	}
+	// EPERM is a live process of another user, such as a cron job's.
+	err = process.Signal(syscall.Signal(0))
+	return err != nil && !errors.Is(err, syscall.EPERM)
+}
+
+func (lock *runLock) release() {
+	if lock.path != "" {
+		os.Remove(lock.path)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLockOfOtherUsersProcessIsNotStale(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("signal 0 probes processes on Unix only")
+	}
+	// pid 1 is always running, as root, so other users get EPERM probing it.
+	if (lockInfo{Pid: 1, StartedAt: time.Now()}).stale() {
+		t.Error("the lock of pid 1 is stale, want a live process of another user kept")
+	}
+}
+
+func TestRemoveStaleLockKeepsReplacedLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+	if err := os.WriteFile(path, []byte(`{"pid":0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run removed the stale lock and took it before this one.
+	os.Remove(path)
+	if err := tryLock(path); err != nil {
+		t.Fatal(err)
+	}
+	removeStaleLock(path, stale)
+	if info, err := readLock(path); err != nil || info.Pid != os.Getpid() {
+		t.Fatalf("the other run's lock was removed: %+v, %v", info, err)
+	}
+
+	current, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeStaleLock(path, current)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the stale lock was kept: %v", err)
+	}
+}
+
+func TestMaintenanceCommandsTakeTheLock(t *testing.T) {
+	config := testConfig(t, nil)
+	lock, err := acquireLock(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.release()
+
+	importFile := filepath.Join(t.TempDir(), "videos.txt")
+	if err := os.WriteFile(importFile, []byte("https://www.youtube.com/watch?v=aaaaaaaaaaa\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commands := map[string]func() error{
+		"-migrate":        func() error { return migrateFiles(config) },
+		"-archive -prune": func() error { return archiveHistory(config, true) },
+		"-import":         func() error { return importVideoList(config, importFile, true) },
+	}
+	for name, command := range commands {
+		if err := command(); !errors.Is(err, ErrLocked) {
+			t.Errorf("%s while a run holds the lock returned %v, want ErrLocked", name, err)
+		}
+	}
+}
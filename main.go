@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,116 +24,513 @@ type PlaylistItemsResponse struct {
 }
 
 type PlaylistItem struct {
-	Snippet Snippet `json:"snippet"`
+	Snippet        Snippet            `json:"snippet"`
+	ContentDetails ItemContentDetails `json:"contentDetails"`
 }
 
 type Snippet struct {
-	Title       string     `json:"title"`
-	PublishedAt string     `json:"publishedAt"`
-	ResourceId  ResourceId `json:"resourceId"`
+	Title                  string     `json:"title"`
+	PublishedAt            string     `json:"publishedAt"`
+	Position               int        `json:"position"`
+	ResourceId             ResourceId `json:"resourceId"`
+	Description            string     `json:"description"`
+	VideoOwnerChannelTitle string     `json:"videoOwnerChannelTitle"`
+	VideoOwnerChannelId    string     `json:"videoOwnerChannelId"`
+}
+
+type ItemContentDetails struct {
+	VideoPublishedAt string `json:"videoPublishedAt"`
 }
 
 type ResourceId struct {
 	VideoId string `json:"videoId"`
 }
 
+type PlaylistsResponse struct {
+	Items []struct {
+		Snippet struct {
+			Thumbnails map[string]struct {
+				Url string `json:"url"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+		ContentDetails struct {
+			ItemCount int `json:"itemCount"`
+		} `json:"contentDetails"`
+	} `json:"items"`
+}
+
 type YoutubePlaylist struct {
-	Playlist  []Video   `json:"videos"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	// SchemaVersion is the file format version, see currentSchemaVersion.
+	SchemaVersion int       `json:"schemaVersion,omitempty"`
+	Playlist      []Video   `json:"videos"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	// ItemCount is the size the API reports for the playlist and
+	// FetchedCount how many items pagination returned. They differ when the
+	// API omits inaccessible items. Neither is set on diffs.
+	ItemCount    int `json:"itemCount,omitempty"`
+	FetchedCount int `json:"fetchedCount,omitempty"`
+	// Thumbnails maps each size of the playlist cover image, such as
+	// "high", to its URL. It is recorded with TrackThumbnails and not set on
+	// diffs.
+	Thumbnails map[string]string `json:"thumbnails,omitempty"`
+	// MetadataChanges is only set on diffs, for changes to the playlist
+	// itself rather than its videos.
+	MetadataChanges []MetadataChange `json:"metadataChanges,omitempty"`
+	// Added, Removed, Renamed, Moved and Deleted are the change categories
+	// of diffs, written in place of videos unless LegacyDiffFormat is set.
+	// Deleted holds videos still in the playlist but deleted or turned
+	// private. Empty categories are left out.
+	Added   []Video  `json:"added,omitempty"`
+	Removed []Video  `json:"removed,omitempty"`
+	Renamed []Rename `json:"renamed,omitempty"`
+	Moved   []Move   `json:"moved,omitempty"`
+	Deleted []Video  `json:"deleted,omitempty"`
+	// AvailabilityChanged holds videos still available whose availability
+	// changed, such as from private to public, with FetchMetadata.
+	AvailabilityChanged []AvailabilityChange `json:"availabilityChanged,omitempty"`
+	// OwnershipChanges is only set on diffs, for videos whose channel was
+	// renamed or that moved to another channel.
+	OwnershipChanges []OwnershipChange `json:"ownershipChanges,omitempty"`
+	// DescriptionChanges is only set on diffs with TrackDescriptions.
+	DescriptionChanges []DescriptionChange `json:"descriptionChanges,omitempty"`
+	// PublishDateChanges is only set on diffs, for videos re-published or
+	// rescheduled since the previous run.
+	PublishDateChanges []PublishDateChange `json:"publishDateChanges,omitempty"`
+	// Note is the external context given with -note or NotesFile to the run
+	// that wrote the file, kept when it moves to history.
+	Note string `json:"note,omitempty"`
+	// PlaylistReplaced is only set on diffs, see Changes.PlaylistReplaced.
+	PlaylistReplaced bool `json:"playlistReplaced,omitempty"`
+	// Credential identifies the API key or account that fetched the
+	// playlist, as a hash for secrets.
+	Credential string `json:"credential,omitempty"`
+}
+
+// MetadataChange is a changed property of the playlist, such as
+// "thumbnails.high".
+type MetadataChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type PublishDateChange struct {
+	Video         Video     `json:"video"`
+	OldUploadedAt time.Time `json:"oldUploadedAt"`
+}
+
+type DescriptionChange struct {
+	Video          Video  `json:"video"`
+	OldDescription string `json:"oldDescription"`
 }
 
+type OwnershipChange struct {
+	Video           Video  `json:"video"`
+	OldChannelTitle string `json:"oldChannelTitle"`
+	OldChannelId    string `json:"oldChannelId"`
+}
+
+// now is the clock stamping UpdatedAt, replaceable for reproducible output.
+var now = time.Now
+
 func newPlaylist(items []Video) *YoutubePlaylist {
-	return &YoutubePlaylist{Playlist: items, UpdatedAt: time.Now()}
+	return &YoutubePlaylist{SchemaVersion: currentSchemaVersion, Playlist: items, UpdatedAt: now()}
+}
+
+// diffOptions tune how playlists are compared.
+type diffOptions struct {
+	deletedTitles []string
+	// membershipOnly compares VideoIds alone, ignoring title and
+	// availability changes.
+	membershipOnly bool
+	descriptions   bool
 }
 
-func (p YoutubePlaylist) subtract(playlist YoutubePlaylist) *YoutubePlaylist {
+func (p YoutubePlaylist) subtract(playlist YoutubePlaylist, options diffOptions) *YoutubePlaylist {
 	playlistMap := make(map[string]Video)
 	for _, video := range p.Playlist {
 		playlistMap[video.VideoId] = video
 	}
 
 	var diff []Video
+	var ownershipChanges []OwnershipChange
+	var descriptionChanges []DescriptionChange
+	var publishDateChanges []PublishDateChange
 	for _, video := range playlist.Playlist {
 		v, found := playlistMap[video.VideoId]
 		if !found {
 			diff = append(diff, video)
+			continue
 		}
-		if found && v.Title != video.Title && (v.Title == "Deleted video" || video.Title == "Deleted video") {
+		if changedOwner(video, v) {
+			ownershipChanges = append(ownershipChanges, OwnershipChange{Video: v, OldChannelTitle: video.ChannelTitle, OldChannelId: video.ChannelId})
+		}
+		if options.descriptions && v.Description != video.Description {
+			descriptionChanges = append(descriptionChanges, DescriptionChange{Video: v, OldDescription: video.Description})
+		}
+		if !v.UploadedAt.IsZero() && !video.UploadedAt.IsZero() && !v.UploadedAt.Equal(video.UploadedAt) {
+			publishDateChanges = append(publishDateChanges, PublishDateChange{Video: v, OldUploadedAt: video.UploadedAt})
+		}
+		// membershipOnly only ignores title and availability changes.
+		if options.membershipOnly {
+			continue
+		}
+		// Like videos that left, changed ones are listed as they were.
+		deletedChange := v.Title != video.Title && (slices.Contains(options.deletedTitles, v.Title) || slices.Contains(options.deletedTitles, video.Title))
+		availabilityChange := v.Availability != "" && video.Availability != "" && v.Availability != video.Availability
+		if deletedChange || availabilityChange {
 			diff = append(diff, video)
 		}
 	}
 
-	return newPlaylist(diff)
+	result := newPlaylist(diff)
+	result.OwnershipChanges = ownershipChanges
+	result.DescriptionChanges = descriptionChanges
+	result.PublishDateChanges = publishDateChanges
+	result.MetadataChanges = thumbnailChanges(playlist.Thumbnails, p.Thumbnails)
+	return result
+}
+
+// thumbnailChanges compares the cover image sizes of two snapshots. Snapshots
+// written without thumbnails never differ.
+func thumbnailChanges(old, current map[string]string) []MetadataChange {
+	if len(old) == 0 || len(current) == 0 {
+		return nil
+	}
+	sizes := make([]string, 0, len(old)+len(current))
+	for size := range old {
+		sizes = append(sizes, size)
+	}
+	for size := range current {
+		if _, found := old[size]; !found {
+			sizes = append(sizes, size)
+		}
+	}
+	slices.Sort(sizes)
+
+	var changes []MetadataChange
+	for _, size := range sizes {
+		if old[size] != current[size] {
+			changes = append(changes, MetadataChange{Field: "thumbnails." + size, Old: old[size], New: current[size]})
+		}
+	}
+	return changes
+}
+
+// changedOwner reports whether the channel of a video differs between two
+// snapshots. Snapshots written before channels were recorded never differ.
+func changedOwner(old, current Video) bool {
+	if old.ChannelId == "" || current.ChannelId == "" {
+		return false
+	}
+	return old.ChannelId != current.ChannelId || old.ChannelTitle != current.ChannelTitle
+}
+
+// empty reports whether a diff has nothing to report.
+func (p YoutubePlaylist) empty() bool {
+	return len(p.Playlist) == 0 && len(p.OwnershipChanges) == 0 && len(p.DescriptionChanges) == 0 && len(p.PublishDateChanges) == 0 &&
+		len(p.MetadataChanges) == 0 && len(p.Added) == 0 && len(p.Removed) == 0 && len(p.Renamed) == 0 &&
+		len(p.Moved) == 0 && len(p.Deleted) == 0 && len(p.AvailabilityChanged) == 0
+}
+
+// sameDiff reports whether diff reports the same changes as previous, as they
+// would be stored, regardless of when and with which note each was written.
+func (config Config) sameDiff(diff, previous YoutubePlaylist) bool {
+	if previous.Playlist == nil && !previous.categorized() {
+		return false
+	}
+	encode := func(p YoutubePlaylist) json.RawMessage {
+		p.UpdatedAt = time.Time{}
+		p.Note = ""
+		p.SchemaVersion = 0
+		p.Playlist = config.StoredFields.projected(slices.Clone(p.Playlist))
+		options := config.fileOptions()
+		options.encryptionKey = nil
+		var data bytes.Buffer
+		if err := writePlaylist(&data, sortedPlaylist(&p, config.SortBy), "json", options); err != nil {
+			return nil
+		}
+		return data.Bytes()
+	}
+	current := encode(diff)
+	return current != nil && bytes.Equal(current, encode(previous))
+}
+
+// excluding drops the changes already reported, unchanged, in previous:
+// the flat list of videos and every change category.
+func (p YoutubePlaylist) excluding(previous YoutubePlaylist) *YoutubePlaylist {
+	type change struct{ videoId, title, availability string }
+	videoChange := func(video Video) change { return change{video.VideoId, video.Title, video.Availability} }
+	result := p
+	result.Playlist = unreported(p.Playlist, previous.Playlist, videoChange)
+	result.Removed = unreported(p.Removed, previous.Playlist, videoChange)
+	result.Deleted = unreported(p.Deleted, previous.Playlist, videoChange)
+
+	result.Added = unreported(p.Added, previous.Added, func(video Video) change { return change{video.VideoId, video.Title, ""} })
+	type rename struct{ videoId, oldTitle, title string }
+	result.Renamed = unreported(p.Renamed, previous.Renamed, func(r Rename) rename { return rename{r.Video.VideoId, r.OldTitle, r.Video.Title} })
+	type move struct {
+		videoId                  string
+		oldPosition, newPosition int
+	}
+	result.Moved = unreported(p.Moved, previous.Moved, func(m Move) move { return move{m.Video.VideoId, m.OldPosition, m.NewPosition} })
+	type availability struct{ videoId, oldAvailability, availability string }
+	result.AvailabilityChanged = unreported(p.AvailabilityChanged, previous.AvailabilityChanged, func(c AvailabilityChange) availability {
+		return availability{c.Video.VideoId, c.OldAvailability, c.Video.Availability}
+	})
+
+	type ownership struct{ videoId, oldChannelId, channelId, channelTitle string }
+	result.OwnershipChanges = unreported(p.OwnershipChanges, previous.OwnershipChanges, func(c OwnershipChange) ownership {
+		return ownership{c.Video.VideoId, c.OldChannelId, c.Video.ChannelId, c.Video.ChannelTitle}
+	})
+	type description struct{ videoId, description string }
+	result.DescriptionChanges = unreported(p.DescriptionChanges, previous.DescriptionChanges, func(c DescriptionChange) description {
+		return description{c.Video.VideoId, c.Video.Description}
+	})
+	type publishDate struct {
+		videoId    string
+		uploadedAt int64
+	}
+	result.PublishDateChanges = unreported(p.PublishDateChanges, previous.PublishDateChanges, func(c PublishDateChange) publishDate {
+		return publishDate{c.Video.VideoId, c.Video.UploadedAt.UnixNano()}
+	})
+	result.MetadataChanges = unreported(p.MetadataChanges, previous.MetadataChanges, func(c MetadataChange) MetadataChange { return c })
+	return &result
+}
+
+// unreported returns the entries of current whose key is not that of an entry
+// of previous. Only a nil current gives nil, which keeps categorized diffs
+// categorized.
+func unreported[T any, K comparable](current, previous []T, key func(T) K) []T {
+	if current == nil {
+		return nil
+	}
+	reported := make(map[K]bool)
+	for _, entry := range previous {
+		reported[key(entry)] = true
+	}
+	kept := make([]T, 0, len(current))
+	for _, entry := range current {
+		if !reported[key(entry)] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// fillsTitles reports whether p has titles for videos previous stored
+// without one, as imports without credentials do.
+func (p YoutubePlaylist) fillsTitles(previous YoutubePlaylist) bool {
+	untitled := make(map[string]bool)
+	for _, video := range previous.Playlist {
+		if video.Title == "" {
+			untitled[video.VideoId] = true
+		}
+	}
+	for _, video := range p.Playlist {
+		if untitled[video.VideoId] && video.Title != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// newSince returns the videos whose VideoId is not in previous.
+func (p YoutubePlaylist) newSince(previous YoutubePlaylist) []Video {
+	known := make(map[string]bool)
+	for _, video := range previous.Playlist {
+		known[video.VideoId] = true
+	}
+	var added []Video
+	for _, video := range p.Playlist {
+		if !known[video.VideoId] {
+			added = append(added, video)
+		}
+	}
+	return added
 }
 
 type Video struct {
-	Title       string    `json:"title"`
-	VideoId     string    `json:"videoId"`
-	PublishedAt time.Time `json:"publishedAt"`
+	Title   string `json:"title"`
+	VideoId string `json:"videoId"`
+	// AddedToPlaylistAt is when the video was added to the playlist, which is
+	// what the API calls the playlist item's publishedAt.
+	AddedToPlaylistAt time.Time `json:"addedToPlaylistAt"`
+	// UploadedAt is when the video itself was published. It is zero for
+	// videos the API no longer describes, such as deleted ones.
+	UploadedAt time.Time `json:"uploadedAt"`
+	// Position is the zero-based index of the video in the playlist.
+	Position int `json:"position"`
+	// Availability is one of public, private, deleted or blocked and is only
+	// set when FetchMetadata is enabled.
+	Availability string `json:"availability,omitempty"`
+	// Duration is the ISO 8601 length of the video, and IsShort whether it
+	// is at most a minute long. Both need FetchMetadata.
+	Duration string `json:"duration,omitempty"`
+	IsShort  bool   `json:"isShort,omitempty"`
+	// ChannelTitle and ChannelId identify the channel that owns the video.
+	ChannelTitle string `json:"channelTitle,omitempty"`
+	ChannelId    string `json:"channelId,omitempty"`
+	// Description is the note on the playlist item, only kept with
+	// TrackDescriptions.
+	Description string `json:"description,omitempty"`
+}
+
+// UnmarshalJSON also accepts files written before AddedToPlaylistAt was
+// split out, where it was stored as publishedAt.
+func (v *Video) UnmarshalJSON(data []byte) error {
+	type video Video
+	var decoded struct {
+		video
+		PublishedAt time.Time `json:"publishedAt"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*v = Video(decoded.video)
+	if v.AddedToPlaylistAt.IsZero() {
+		v.AddedToPlaylistAt = decoded.PublishedAt
+	}
+	return nil
 }
 
-func newVideo(item *PlaylistItem) *Video {
+// ErrMissingTime is returned by newVideo for items whose publishedAt is
+// missing or invalid, see Config.MissingTimeBehavior.
+var ErrMissingTime = errors.New("missing or invalid publishedAt")
+
+// newVideo converts a playlist item. An item with a missing or invalid time
+// is still converted, with the zero time, alongside an ErrMissingTime error.
+func newVideo(item *PlaylistItem) (*Video, error) {
+	var errs []error
 	parsedTime, err := time.Parse(time.RFC3339, item.Snippet.PublishedAt)
 	if err != nil {
-		log.Fatalf("Error parsing time: %v", err)
+		errs = append(errs, fmt.Errorf("%w: snippet.publishedAt %q", ErrMissingTime, item.Snippet.PublishedAt))
 	}
 
-	return &Video{Title: item.Snippet.Title, VideoId: item.Snippet.ResourceId.VideoId, PublishedAt: parsedTime}
+	var uploadedAt time.Time
+	if item.ContentDetails.VideoPublishedAt != "" {
+		uploadedAt, err = time.Parse(time.RFC3339, item.ContentDetails.VideoPublishedAt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: contentDetails.videoPublishedAt %q", ErrMissingTime, item.ContentDetails.VideoPublishedAt))
+		}
+	}
 
+	return &Video{Title: item.Snippet.Title, VideoId: item.Snippet.ResourceId.VideoId, AddedToPlaylistAt: parsedTime, UploadedAt: uploadedAt, Position: item.Snippet.Position,
+		ChannelTitle: item.Snippet.VideoOwnerChannelTitle, ChannelId: item.Snippet.VideoOwnerChannelId,
+		Description: item.Snippet.Description}, errors.Join(errs...)
 }
 
-func fetchPlaylistItems(apiKey, playlistID, pageToken string) (*PlaylistItemsResponse, error) {
-	baseURL := "https://www.googleapis.com/youtube/v3/playlistItems"
+func fetchPlaylistItems(client *apiClient, playlistID, pageToken string, maxResults int) (*PlaylistItemsResponse, error) {
 	params := url.Values{}
-	params.Set("part", "snippet")
-	params.Set("maxResults", "50")
+	params.Set("part", "snippet,contentDetails")
+	params.Set("maxResults", strconv.Itoa(maxResults))
 	params.Set("playlistId", playlistID)
-	params.Set("key", apiKey)
 	if pageToken != "" {
 		params.Set("pageToken", pageToken)
 	}
-	url := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 
-	resp, err := http.Get(url)
-	if err != nil {
+	var response PlaylistItemsResponse
+	if err := client.getJSON("playlistItems", params, &response); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API call failed, status code: %d", resp.StatusCode)
-	}
+	return &response, nil
+}
 
-	var response PlaylistItemsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
+// fetchPlaylistInfo returns the number of items the API reports for a
+// playlist and the URL of each size of its cover image.
+func fetchPlaylistInfo(client *apiClient, playlistID string) (int, map[string]string, error) {
+	params := url.Values{}
+	params.Set("part", "snippet,contentDetails")
+	params.Set("id", playlistID)
+
+	var response PlaylistsResponse
+	if err := client.getJSON("playlists", params, &response); err != nil {
+		return 0, nil, err
+	}
+	if len(response.Items) == 0 {
+		return 0, nil, ErrPlaylistNotFound
 	}
+	item := response.Items[0]
+	thumbnails := make(map[string]string, len(item.Snippet.Thumbnails))
+	for size, thumbnail := range item.Snippet.Thumbnails {
+		thumbnails[size] = thumbnail.Url
+	}
+	return item.ContentDetails.ItemCount, thumbnails, nil
+}
 
-	return &response, nil
+func (config Config) writeFile(playlist *YoutubePlaylist, fileName string) {
+	config.writeOutput(fileName, "JSON", func(w io.Writer) error {
+		return writePlaylist(w, sortedPlaylist(playlist, config.SortBy), "json", config.fileOptions())
+	})
 }
 
-func writeFile(playlist *YoutubePlaylist, dirPath string, fileName string) {
+// writeJSONFile writes v as JSON to fileName in DirPath, encrypted when an
+// encryption key is configured.
+func (config Config) writeJSONFile(fileName string, v any) {
+	config.writeOutput(fileName, "JSON", func(w io.Writer) error {
+		return encrypting(w, config.encryptionKey, func(w io.Writer) error {
+			return encodeJSON(w, v, config.writeOptions())
+		})
+	})
+}
 
-	jsonData, err := json.MarshalIndent(playlist, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
+func (config Config) writeOutput(fileName string, kind string, write func(w io.Writer) error) {
+	filePath := filepath.Join(config.DirPath, fileName)
+
+	err := createFile(filePath, config.FileMode, write)
+	if err != nil && config.TolerateReadOnlyDirPath && readOnlyError(err) {
+		log.Printf("WARNING: not writing %s to %s: %v", kind, filePath, err)
 		return
 	}
+	if err != nil {
+		log.Fatalf("Error writing %s to file: %v", kind, err)
+	}
 
-	filePath := filepath.Join(dirPath, fileName)
+	fmt.Println(kind, "data written to", filePath)
+}
 
-	file, err := os.Create(filePath)
-	if err != nil {
-		log.Fatalf("Error creating file: %v", err)
+// writeOutputs writes every extra format in OutputFormats next to the
+// canonical JSON files, named after PlaylistFileName or DiffFileName, and
+// the StrmDir files.
+func (config Config) writeOutputs(playlist *YoutubePlaylist, changes *Changes) {
+	for _, name := range config.OutputFormats {
+		format, _ := findOutputFormat(name)
+		switch {
+		case format.name == "json":
+		case format.write != nil:
+			fileName := config.outputFileName(format)
+			config.writeOutput(fileName, format.name, func(w io.Writer) error {
+				return writePlaylist(w, sortedPlaylist(playlist, config.SortBy), format.name, config.exportOptions())
+			})
+		case format.writeChanges != nil:
+			fileName := config.outputFileName(format)
+			config.writeOutput(fileName, format.name, func(w io.Writer) error {
+				return writeChanges(w, changes, format.name, config.exportOptions())
+			})
+		}
 	}
-	defer file.Close()
+	config.writeStrm(playlist)
+}
 
-	_, err = file.Write(jsonData)
-	if err != nil {
-		log.Fatalf("Error writing JSON to file: %v", err)
+// outputFileName names the file of format: the playlist or diff file name
+// with the format's extension, or the one FormatExtensions gives it.
+func (config Config) outputFileName(format outputFormat) string {
+	if format.name == "json" {
+		return config.PlaylistFileName
 	}
+	extension := format.extension
+	if override, found := config.FormatExtensions[format.name]; found {
+		extension = override
+	}
+	if format.writeChanges != nil {
+		return baseName(config.DiffFileName) + extension
+	}
+	return baseName(config.PlaylistFileName) + extension
+}
 
-	fmt.Println("JSON data written to", filePath)
+func baseName(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
 }
 
 func readPlaylistFromFile(config Config, fileName string) (YoutubePlaylist, error) {
@@ -140,9 +543,48 @@ func readPlaylistFromFile(config Config, fileName string) (YoutubePlaylist, erro
 		return youtubePlaylist, err
 	}
 
-	err = json.Unmarshal(fileData, &youtubePlaylist)
+	return config.decodePlaylist(fileData)
+}
+
+// ErrCorruptFile is returned for playlist files that exist but cannot be
+// parsed, which must not be mistaken for a first run and overwritten.
+var ErrCorruptFile = errors.New("corrupt playlist file")
+
+// readPlaylist reads a playlist file's contents from r, such as stdin.
+func readPlaylist(config Config, r io.Reader) (YoutubePlaylist, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return YoutubePlaylist{}, err
+	}
+	return config.decodePlaylist(data)
+}
+
+// decodePlaylist decrypts and unmarshals a playlist file, migrating it to
+// currentSchemaVersion in memory.
+func (config Config) decodePlaylist(data []byte) (YoutubePlaylist, error) {
+	youtubePlaylist, err := config.unmarshalPlaylist(data)
+	if err != nil {
+		return youtubePlaylist, err
+	}
+	migratePlaylist(&youtubePlaylist)
+	youtubePlaylist.flatten()
+	return youtubePlaylist, nil
+}
+
+func (config Config) unmarshalPlaylist(data []byte) (YoutubePlaylist, error) {
+	var youtubePlaylist YoutubePlaylist
+
+	var err error
+	if isEncrypted(data) {
+		data, err = decrypt(config.encryptionKey, data)
+		if err != nil {
+			return youtubePlaylist, err
+		}
+	}
+
+	err = json.Unmarshal(data, &youtubePlaylist)
 	if err != nil {
-		return youtubePlaylist, fmt.Errorf("error unmarshalling JSON: %w", err)
+		return youtubePlaylist, fmt.Errorf("%w: error unmarshalling JSON: %w", ErrCorruptFile, err)
 	}
 
 	return youtubePlaylist, nil
@@ -155,23 +597,310 @@ type Config struct {
 	DiffFileName     string `json:"diffFileName"`
 	PlaylistFileName string `json:"playlistFileName"`
 	KeepHistory      bool   `json:"keepHistory"`
+	// AlwaysWriteDiff writes diff.json on every run, even when it is empty.
+	// When false, diff.json only exists while the latest run found changes;
+	// a stale one is removed, after being archived if KeepHistory is set.
+	AlwaysWriteDiff bool `json:"alwaysWriteDiff"`
+	// ServiceAccountKeyFile is the path to a Google service account JSON key.
+	// When set, requests are authorized with a youtube.readonly token and
+	// ApiKey becomes optional.
+	ServiceAccountKeyFile string `json:"serviceAccountKeyFile"`
+	// BaselineFile, relative to DirPath, is diffed against instead of
+	// PlaylistFileName. PlaylistFileName is still updated as usual. "-"
+	// reads the baseline from stdin, so runs can be chained in a pipeline.
+	BaselineFile string `json:"baselineFile"`
+	// OAuthClientId, OAuthClientSecret and OAuthRefreshToken authorize as a
+	// YouTube user, which personal playlists such as Liked videos (LL) need.
+	// They take precedence over ServiceAccountKeyFile.
+	OAuthClientId     string `json:"oauthClientId"`
+	OAuthClientSecret string `json:"oauthClientSecret"`
+	OAuthRefreshToken string `json:"oauthRefreshToken"`
+	// FetchMetadata looks every video up on the videos endpoint to record its
+	// availability. Costs one extra API call per 50 videos.
+	FetchMetadata bool `json:"fetchMetadata"`
+	// RegionCode is the ISO 3166-1 country used to decide whether a region
+	// restricted video counts as blocked. When empty any restriction does.
+	RegionCode string `json:"regionCode"`
+	// ShrinkGuard is the largest drop in video count, as a percentage of the
+	// stored playlist, accepted without -force. Zero disables the check.
+	ShrinkGuard int `json:"shrinkGuard"`
+	// IncrementalDiff leaves out changes the previous diff.json already
+	// reported, so frequent polling only surfaces what is new this run.
+	IncrementalDiff bool `json:"incrementalDiff"`
+	// MaxRetries bounds how often a failed request is retried. Delays grow
+	// from RetryBaseDelay by RetryFactor per attempt, capped at RetryMaxDelay;
+	// a 429 honors Retry-After up to the same cap.
+	MaxRetries     int      `json:"maxRetries"`
+	RetryBaseDelay Duration `json:"retryBaseDelay"`
+	RetryMaxDelay  Duration `json:"retryMaxDelay"`
+	RetryFactor    float64  `json:"retryFactor"`
+	// PrettyJSON indents the JSON files, which keeps them readable and
+	// diff-friendly under version control. Defaults to true.
+	PrettyJSON bool `json:"prettyJSON"`
+	// LockMode decides what happens when another run holds the lock in
+	// DirPath: "fail" (the default) exits, "wait" blocks until it is released.
+	LockMode string `json:"lockMode"`
+	// SortBy orders videos in written files by "title", "publishedAt" (when
+	// added to the playlist), "uploadedAt" or "position". Ties are broken by
+	// VideoId. The default, "none", keeps API order.
+	SortBy string `json:"sortBy"`
+	// OutputFormats lists extra formats, from -list-formats, written each
+	// time the playlist changes. The JSON files are always written.
+	OutputFormats []string `json:"outputFormats"`
+	// FormatExtensions overrides the extension of an output format's file,
+	// such as {"urls": ".urls.txt"}, to tell apart formats that share one.
+	FormatExtensions map[string]string `json:"formatExtensions"`
+	// ApiKeyFile is read for the API key, for secrets mounted as files. The
+	// YOUTUBE_API_KEY environment variable wins over it, and it over ApiKey.
+	ApiKeyFile string `json:"apiKeyFile"`
+	// Playlists tracks several playlists in one run, replacing PlaylistId.
+	Playlists []PlaylistConfig `json:"playlists"`
+	// PlaylistSubdirectories keeps each of Playlists in its own directory
+	// under DirPath, named by its Alias or PlaylistId.
+	PlaylistSubdirectories bool `json:"playlistSubdirectories"`
+	// JSONNaming is "camelCase" (the default) or "snake_case" for the keys of
+	// JSON written for OutputFormats. Canonical files always use camelCase.
+	JSONNaming string `json:"jsonNaming"`
+	// Concurrency is how many of Playlists are processed at once. Defaults to 1.
+	Concurrency int `json:"concurrency"`
+	// InterPlaylistDelay pauses before starting each playlist after the
+	// first, to spread API calls out when tracking many playlists.
+	InterPlaylistDelay Duration `json:"interPlaylistDelay"`
+	// DeletedTitles are the titles the API gives deleted videos, which vary
+	// with the language of the response. Defaults to defaultDeletedTitles.
+	DeletedTitles []string `json:"deletedTitles"`
+	// AdaptivePageSize shrinks the page size when requests keep timing out,
+	// and grows it back once they succeed again.
+	AdaptivePageSize bool `json:"adaptivePageSize"`
+	// IgnoreTitleChanges diffs on VideoId membership alone: only added and
+	// removed videos are reported, never renames or deletions by title.
+	IgnoreTitleChanges bool `json:"ignoreTitleChanges"`
+	// TemplateFile is a Go text/template rendered by the template output
+	// format with the YoutubePlaylist as data. Besides the builtins it can
+	// call watchURL .VideoId and formatTime "2006-01-02" .UploadedAt.
+	TemplateFile string `json:"templateFile"`
+	// Shorts is "include" (the default) to track Shorts like other videos,
+	// "exclude" to drop them, or "separate" to drop them from the playlist
+	// and write them to ShortsFileName instead. Needs FetchMetadata.
+	Shorts         string `json:"shorts"`
+	ShortsFileName string `json:"shortsFileName"`
+	// EncryptionKeyFile holds a 32 byte key, hex or base64 encoded, used to
+	// encrypt the playlist, diff and history files with AES-GCM. Unencrypted
+	// files are still read, so enabling it needs no migration.
+	EncryptionKeyFile string `json:"encryptionKeyFile"`
+	// FileMode is the octal permission, such as "0600", of the files written
+	// to DirPath. Unset keeps the default of 0666 minus the umask.
+	FileMode FileMode `json:"fileMode"`
+	// RequestTimeout bounds each API request, and a request that times out
+	// is retried like other transport errors. RunTimeout bounds the whole
+	// Run, retries and backoff included, and stops it once it passes. Zero
+	// disables either one.
+	RequestTimeout Duration `json:"requestTimeout"`
+	RunTimeout     Duration `json:"runTimeout"`
+	// TrackDescriptions stores the note on each playlist item and reports
+	// edits to it in the diff. The first run after enabling it reports every
+	// item that has a note.
+	TrackDescriptions bool `json:"trackDescriptions"`
+	// TrackThumbnails stores the playlist cover image URLs and reports
+	// changes to them, such as a re-branded playlist, under metadataChanges
+	// in the diff.
+	TrackThumbnails bool `json:"trackThumbnails"`
+	// RenotifyUnchanged publishes a playlist's changes again when its diff
+	// is the same as the stored one, which is otherwise kept as it is and
+	// not published.
+	RenotifyUnchanged bool `json:"renotifyUnchanged"`
+	// RecoverDeletedTitles replaces the "Deleted video" and "Private video"
+	// titles in the diff with the latest real title the stored playlist or
+	// its history recorded, see bestKnownTitle.
+	RecoverDeletedTitles bool `json:"recoverDeletedTitles"`
+	// LegacyDiffFormat writes diffs as a flat list of videos under
+	// "videos", as earlier versions did, instead of the added, removed,
+	// renamed, moved and deleted categories.
+	LegacyDiffFormat bool `json:"legacyDiffFormat"`
+	// WarnAPISchemaDrift logs a warning when an API response has fields
+	// that are not tracked or lacks ones that are, such as
+	// snippet.resourceId.videoId, to notice API changes before they show up
+	// as empty data.
+	WarnAPISchemaDrift bool `json:"warnAPISchemaDrift"`
+	// SearchQuery tracks the top SearchMaxResults search results for a query,
+	// ranked by relevance, instead of PlaylistId. Each page of up to 50
+	// results costs 100 quota units, against 1 for a playlistItems page.
+	// SearchMaxResults defaults to 50.
+	SearchQuery      string `json:"searchQuery"`
+	SearchMaxResults int    `json:"searchMaxResults"`
+	// EarlyStopOnKnown stops paginating at the first page whose videos are
+	// all in the stored playlist, and takes the rest from it. It suits
+	// newest-first, append-only playlists such as uploads, where it saves
+	// most calls; videos removed beyond that page go unnoticed, so leave it
+	// off for playlists that are reordered or edited.
+	EarlyStopOnKnown bool `json:"earlyStopOnKnown"`
+	// EmptyPageRetries retries a page this many times when it comes back
+	// empty and last while the API reports more items than were fetched,
+	// which the API occasionally does mid-playlist. Each check costs a
+	// playlists call. Zero accepts empty pages.
+	EmptyPageRetries int `json:"emptyPageRetries"`
+	// MissingTimeBehavior is what happens to playlist items with a missing
+	// or invalid publishedAt: "zero" (the default) tracks them with the zero
+	// time and a warning, "skip" leaves them out and "error" fails the
+	// playlist.
+	MissingTimeBehavior string `json:"missingTimeBehavior"`
+	// ReportLocale formats the numbers of CountsFile and the formatCount and
+	// formatDuration template functions for spreadsheets and readers of a
+	// locale: "en", "de", "es", "fr", "it" or "nl" group thousands, write
+	// durations as h:mm:ss and, but for "en", separate CSV fields with
+	// semicolons. Unset keeps the machine-readable plain integers and ISO
+	// 8601 durations.
+	ReportLocale string `json:"reportLocale"`
+	// Syslog sends a line per changed playlist to the system log, as the
+	// user facility at SyslogPriority ("info" by default) and tagged with
+	// SyslogTag ("playlist_machine" by default). Where there is no syslog
+	// the lines go to stderr.
+	Syslog         bool   `json:"syslog"`
+	SyslogPriority string `json:"syslogPriority"`
+	SyslogTag      string `json:"syslogTag"`
+	// RunName labels this setup in log lines and the summary, to tell
+	// several configs apart.
+	RunName string `json:"runName"`
+	// MaxResponseBytes caps the size of an API response body, guarding
+	// against abnormal payloads. It defaults to 10 MiB.
+	MaxResponseBytes int64 `json:"maxResponseBytes"`
+	// RetryStatusCodes are the HTTP status codes of API responses worth
+	// retrying, by default 429, 500, 502, 503 and 504. Transport errors are
+	// always retried.
+	RetryStatusCodes []int `json:"retryStatusCodes"`
+	// TrackOnlyVideoIds, when set, keeps only these videos of the fetched
+	// playlist, so the stored files follow just a few videos of a large one.
+	TrackOnlyVideoIds []string `json:"trackOnlyVideoIds"`
+	// TolerateReadOnlyDirPath logs a warning instead of failing when DirPath
+	// cannot be written to, such as on an immutable filesystem, so the run
+	// still publishes its changes. It needs PublishBroker for that. Runs then
+	// go unlocked and nothing is stored for the next one to diff against.
+	TolerateReadOnlyDirPath bool `json:"tolerateReadOnlyDirPath"`
+	// DeduplicateOnWrite keeps only the first entry of a VideoId that is in
+	// the playlist more than once.
+	DeduplicateOnWrite bool `json:"deduplicateOnWrite"`
+	// CheckpointMaxAge makes multi-playlist runs resumable: a run restarted
+	// after an interrupted one skips the playlists that run completed less
+	// than this long ago. Zero disables checkpoints.
+	CheckpointMaxAge Duration `json:"checkpointMaxAge"`
+	// PublishBroker, when set to "redis", publishes the changes of every
+	// playlist that changed as JSON to PublishChannel on the broker at
+	// PublishAddr, in addition to the files.
+	PublishBroker   string `json:"publishBroker"`
+	PublishAddr     string `json:"publishAddr"`
+	PublishPassword string `json:"publishPassword"`
+	PublishChannel  string `json:"publishChannel"`
+	// RemovedFileName, when set, is a file in DirPath rewritten every run
+	// with only the videos removed since the previous run.
+	RemovedFileName string `json:"removedFileName"`
+	// IndentString is the indentation per level of pretty JSON, two spaces
+	// by default. It may only hold spaces and tabs.
+	IndentString string `json:"indentString"`
+	// NotesFile holds a note attached to the playlist and diff written by
+	// the next run, unless -note gives one.
+	NotesFile string `json:"notesFile"`
+	// StrmDir, relative to DirPath unless absolute, receives a .strm file
+	// per video for media servers. In multi-playlist mode each playlist gets
+	// a subdirectory named after it.
+	StrmDir string `json:"strmDir"`
+	// ReplacementGuard refuses to overwrite the stored playlist without
+	// -force when none of its videos are left, as when a playlist ID was
+	// reused for a different playlist.
+	ReplacementGuard bool `json:"replacementGuard"`
+	// UpdatedAtSource is "now" (the default) to stamp files with the time of
+	// the run, or "latestAdded" to use the latest AddedToPlaylistAt, so an
+	// unchanged playlist is written byte for byte the same.
+	UpdatedAtSource string `json:"updatedAtSource"`
+	// CountsFile, when set, is a CSV file in DirPath that every run appends
+	// its timestamp, video count and added and removed counts to.
+	CountsFile string `json:"countsFile"`
+	// DiffNewVideos writes the videos added since the last run as the diff
+	// when none were removed. Otherwise such a run only updates the playlist
+	// and leaves the diff empty, since diffs track what left the playlist.
+	DiffNewVideos bool `json:"diffNewVideos"`
+	// HistoryArchiveFileName is the file in DirPath -archive gathers the
+	// history snapshots into.
+	HistoryArchiveFileName string `json:"historyArchiveFileName"`
+	// MaxIdleConns, MaxIdleConnsPerHost and IdleConnTimeout tune the pool of
+	// API connections kept open between requests. Unset keeps Go's defaults.
+	MaxIdleConns        int      `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int      `json:"maxIdleConnsPerHost"`
+	IdleConnTimeout     Duration `json:"idleConnTimeout"`
+	// StoredFields lists the video fields, such as "title", kept in the
+	// JSON files besides videoId, to shrink them. Unset keeps every field.
+	// Fields left out are also ignored when diffing.
+	StoredFields storedFields `json:"storedFields"`
+
+	encryptionKey []byte
+	// maxVideos is the MaxVideos of the playlist being processed, see
+	// PlaylistConfig.
+	maxVideos int
+}
+
+var defaultDeletedTitles = []string{
+	"Deleted video",
+	"Vidéo supprimée",
+	"Gelöschtes Video",
+	"Video eliminado",
+	"Vídeo excluído",
+	"Video eliminato",
+	"Удалённое видео",
+	"削除された動画",
+}
+
+// Duration reads durations from config as strings such as "1.5s" or "2m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
 }
 
+const configFileName = "config.json"
+
 func newConfig() *Config {
-	file, err := os.Open("config.json")
+	config, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Failed to open config file: %v", err)
+		log.Fatal(err)
 	}
-	defer file.Close()
+	return config
+}
 
-	bytes, err := io.ReadAll(file)
+func loadConfig() (*Config, error) {
+	return loadConfigFile(configFileName)
+}
+
+func loadConfigFile(fileName string) (*Config, error) {
+	bytes, err := readConfigTree(fileName, nil)
 	if err != nil {
-		log.Fatalf("Failed to read config file: %v", err)
+		return nil, err
 	}
 
-	var config Config
+	config := Config{PrettyJSON: true}
 	if err := json.Unmarshal(bytes, &config); err != nil {
-		log.Fatalf("Failed to unmarshal config file: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal config file: %w", err)
+	}
+	config.expandEnv()
+	if err := config.resolveApiKey(); err != nil {
+		return nil, fmt.Errorf("failed to read API key: %w", err)
+	}
+	if config.EncryptionKeyFile != "" {
+		config.encryptionKey, err = readEncryptionKey(config.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key: %w", err)
+		}
 	}
 	if config.DirPath == "" {
 		wd, _ := os.Getwd()
@@ -184,74 +913,537 @@ func newConfig() *Config {
 	if config.PlaylistFileName == "" {
 		config.PlaylistFileName = "playlist.json"
 	}
+	if config.ShortsFileName == "" {
+		config.ShortsFileName = "shorts.json"
+	}
+	if len(config.DeletedTitles) == 0 {
+		config.DeletedTitles = defaultDeletedTitles
+	}
+	if config.SearchMaxResults == 0 {
+		config.SearchMaxResults = searchPageSize
+	}
+	if config.MissingTimeBehavior == "" {
+		config.MissingTimeBehavior = "zero"
+	}
+	if config.SyslogPriority == "" {
+		config.SyslogPriority = "info"
+	}
+	if config.SyslogTag == "" {
+		config.SyslogTag = "playlist_machine"
+	}
+	if config.RetryBaseDelay == 0 {
+		config.RetryBaseDelay = Duration(time.Second)
+	}
+	if config.RetryMaxDelay == 0 {
+		config.RetryMaxDelay = Duration(30 * time.Second)
+	}
+	if config.RetryFactor == 0 {
+		config.RetryFactor = 2
+	}
+	if config.MaxResponseBytes == 0 {
+		config.MaxResponseBytes = defaultMaxResponseBytes
+	}
+	if config.HistoryArchiveFileName == "" {
+		config.HistoryArchiveFileName = "history.json"
+	}
+	if config.IndentString == "" {
+		config.IndentString = "  "
+	}
+	if config.RetryStatusCodes == nil {
+		config.RetryStatusCodes = defaultRetryStatusCodes
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-	fmt.Printf("Config: %+v\n", config)
-	return &config
+	return &config, nil
 }
 
-func (config Config) saveHistory(oldDiff YoutubePlaylist, oldPlaylist YoutubePlaylist) {
-	fileName := fmt.Sprintf("%s_%s", oldPlaylist.UpdatedAt.Format(time.RFC3339), config.PlaylistFileName)
-	writeFile(&oldPlaylist, config.DirPath, fileName)
-	if oldDiff.Playlist != nil {
-		diffFileName := fmt.Sprintf("%s_%s", oldDiff.UpdatedAt.Format(time.RFC3339), config.DiffFileName)
-		writeFile(&oldDiff, config.DirPath, diffFileName)
-		os.Remove(filepath.Join(config.DirPath, config.DiffFileName))
+const apiKeyEnv = "YOUTUBE_API_KEY"
 
+func (config *Config) resolveApiKey() error {
+	if apiKey := os.Getenv(apiKeyEnv); apiKey != "" {
+		config.ApiKey = apiKey
+		return nil
+	}
+	if config.ApiKeyFile != "" {
+		data, err := os.ReadFile(config.ApiKeyFile)
+		if err != nil {
+			return err
+		}
+		config.ApiKey = strings.TrimSpace(string(data))
 	}
+	return nil
 }
 
-func main() {
-	config := newConfig()
-	var videos []Video
+var ErrNoPlaylists = errors.New("no playlist configured, set playlistId or searchQuery or add entries to playlists in " + configFileName)
+
+func (config Config) validate() error {
+	if config.BaselineFile == "-" && len(config.Playlists) > 0 {
+		return errors.New("a baseline from stdin only works with a single playlist")
+	}
+	switch config.Shorts {
+	case "", "include":
+	case "exclude", "separate":
+		if !config.FetchMetadata {
+			return fmt.Errorf("shorts %q needs fetchMetadata to know video durations", config.Shorts)
+		}
+	default:
+		return fmt.Errorf("shorts must be \"include\", \"exclude\" or \"separate\", got %q", config.Shorts)
+	}
+	if config.PlaylistId == "" && len(config.Playlists) == 0 && config.SearchQuery == "" {
+		return ErrNoPlaylists
+	}
+	if config.SearchQuery != "" && (config.PlaylistId != "" || len(config.Playlists) > 0) {
+		return errors.New("searchQuery replaces playlistId and playlists, set only one of them")
+	}
+	if err := validateSyslogPriority(config.SyslogPriority); err != nil {
+		return err
+	}
+	if _, err := findNumberLocale(config.ReportLocale); err != nil {
+		return err
+	}
+	switch config.MissingTimeBehavior {
+	case "", "zero", "skip", "error":
+	default:
+		return fmt.Errorf("missingTimeBehavior must be \"zero\", \"skip\" or \"error\", got %q", config.MissingTimeBehavior)
+	}
+	if config.EmptyPageRetries < 0 {
+		return errors.New("emptyPageRetries must not be negative")
+	}
+	if config.SearchMaxResults < 0 {
+		return errors.New("searchMaxResults must not be negative")
+	}
+	if config.InterPlaylistDelay < 0 {
+		return errors.New("interPlaylistDelay must not be negative")
+	}
+	if config.Concurrency < 0 {
+		return errors.New("concurrency must not be negative")
+	}
+	if strings.Trim(config.IndentString, " \t") != "" {
+		return fmt.Errorf("indentString may only hold spaces and tabs, got %q", config.IndentString)
+	}
+	if config.UpdatedAtSource != "" && config.UpdatedAtSource != "now" && config.UpdatedAtSource != "latestAdded" {
+		return fmt.Errorf("updatedAtSource must be \"now\" or \"latestAdded\", got %q", config.UpdatedAtSource)
+	}
+	if err := validateStoredFields(config.StoredFields); err != nil {
+		return err
+	}
+	if config.MaxResponseBytes < 0 {
+		return errors.New("maxResponseBytes must not be negative")
+	}
+	if _, err := newPublisher(&config); err != nil {
+		return err
+	}
+	if config.PublishBroker != "" && (config.PublishAddr == "" || config.PublishChannel == "") {
+		return errors.New("publishBroker needs publishAddr and publishChannel")
+	}
+	// Only the summary's counts would be left of the changes otherwise.
+	if config.TolerateReadOnlyDirPath && config.PublishBroker == "" {
+		return errors.New("tolerateReadOnlyDirPath needs publishBroker, so the changes still go somewhere")
+	}
+	if config.MaxIdleConns < 0 || config.MaxIdleConnsPerHost < 0 || config.IdleConnTimeout < 0 {
+		return errors.New("maxIdleConns, maxIdleConnsPerHost and idleConnTimeout must not be negative")
+	}
+	if config.CheckpointMaxAge < 0 {
+		return errors.New("checkpointMaxAge must not be negative")
+	}
+	if config.RequestTimeout < 0 || config.RunTimeout < 0 {
+		return errors.New("requestTimeout and runTimeout must not be negative")
+	}
+	if config.JSONNaming != "" && config.JSONNaming != "camelCase" && config.JSONNaming != "snake_case" {
+		return fmt.Errorf("jsonNaming must be \"camelCase\" or \"snake_case\", got %q", config.JSONNaming)
+	}
+	names := make(map[string]bool)
+	for _, playlist := range config.Playlists {
+		if err := playlist.validate(); err != nil {
+			return err
+		}
+		if names[playlist.name()] {
+			return fmt.Errorf("playlist name %q is used twice", playlist.name())
+		}
+		names[playlist.name()] = true
+		if err := checkSpecialPlaylist(playlist.PlaylistId, config.OAuthRefreshToken != ""); err != nil {
+			return fmt.Errorf("playlist %s: %w", playlist.name(), err)
+		}
+	}
+	for _, name := range config.OutputFormats {
+		if _, found := findOutputFormat(name); !found {
+			return fmt.Errorf("unknown output format %q, see -list-formats", name)
+		}
+		if name == "template" {
+			if config.TemplateFile == "" {
+				return errors.New("the template output format needs templateFile")
+			}
+			if _, err := parseTemplate(config.TemplateFile); err != nil {
+				return fmt.Errorf("invalid templateFile: %w", err)
+			}
+		}
+	}
+	for name := range config.FormatExtensions {
+		if _, found := findOutputFormat(name); !found {
+			return fmt.Errorf("formatExtensions names unknown output format %q, see -list-formats", name)
+		}
+	}
+	written := map[string]string{config.PlaylistFileName: "the playlist", config.DiffFileName: "the diff"}
+	for _, name := range config.OutputFormats {
+		format, _ := findOutputFormat(name)
+		if format.name == "json" {
+			continue
+		}
+		fileName := config.outputFileName(format)
+		if other, found := written[fileName]; found {
+			return fmt.Errorf("output format %s would overwrite %s in %s, set formatExtensions to tell them apart", name, other, fileName)
+		}
+		written[fileName] = "output format " + name
+	}
+	if _, found := videoOrderings[config.SortBy]; !found && config.SortBy != "" && config.SortBy != "none" {
+		return fmt.Errorf("unknown sortBy %q", config.SortBy)
+	}
+	if config.LockMode != "" && config.LockMode != "fail" && config.LockMode != "wait" {
+		return fmt.Errorf("lockMode must be \"fail\" or \"wait\", got %q", config.LockMode)
+	}
+	if err := checkSpecialPlaylist(config.PlaylistId, config.OAuthRefreshToken != ""); err != nil {
+		return err
+	}
+	if config.OAuthRefreshToken != "" && (config.OAuthClientId == "" || config.OAuthClientSecret == "") {
+		return errors.New("oauthRefreshToken needs oauthClientId and oauthClientSecret")
+	}
+	if config.MaxRetries < 0 {
+		return errors.New("maxRetries must not be negative")
+	}
+	if config.RetryBaseDelay > config.RetryMaxDelay {
+		return fmt.Errorf("retryBaseDelay %s is larger than retryMaxDelay %s", time.Duration(config.RetryBaseDelay), time.Duration(config.RetryMaxDelay))
+	}
+	if config.RetryFactor <= 1 {
+		return fmt.Errorf("retryFactor must be greater than 1, got %g", config.RetryFactor)
+	}
+	return nil
+}
 
-	pageToken := ""
+const redacted = "REDACTED"
 
-	for {
-		response, err := fetchPlaylistItems(config.ApiKey, config.PlaylistId, pageToken)
-		if err != nil {
-			log.Fatalf("Error fetching playlist items: %v", err)
+// redacted returns a copy of config safe to print, with credentials masked.
+func (config Config) redacted() Config {
+	for _, secret := range []*string{&config.ApiKey, &config.OAuthClientSecret, &config.OAuthRefreshToken, &config.PublishPassword} {
+		if *secret != "" {
+			*secret = redacted
 		}
+	}
+	return config
+}
 
-		for _, item := range response.Items {
-			video := *newVideo(&item)
-			videos = append(videos, video)
+// initConfig writes a config.json template listing every field with its
+// default and a comment, and refuses to replace an existing one.
+func initConfig() error {
+	template := Config{
+		DiffFileName:           "diff.json",
+		PlaylistFileName:       "playlist.json",
+		MaxRetries:             3,
+		RetryBaseDelay:         Duration(time.Second),
+		RetryMaxDelay:          Duration(30 * time.Second),
+		RetryFactor:            2,
+		PrettyJSON:             true,
+		DeletedTitles:          defaultDeletedTitles,
+		Shorts:                 "include",
+		ShortsFileName:         "shorts.json",
+		MaxResponseBytes:       defaultMaxResponseBytes,
+		RetryStatusCodes:       defaultRetryStatusCodes,
+		IndentString:           "  ",
+		HistoryArchiveFileName: "history.json",
+		LockMode:               "fail",
+		SortBy:                 "none",
+		JSONNaming:             "camelCase",
+		MissingTimeBehavior:    "zero",
+		SyslogPriority:         "info",
+		SyslogTag:              "playlist_machine",
+		UpdatedAtSource:        "now",
+		SearchMaxResults:       searchPageSize,
+	}
+	jsonData, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return err
+	}
+	jsonData = commented(jsonData)
+
+	file, err := os.OpenFile(configFileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists, not overwriting it", configFileName)
 		}
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(jsonData, '\n'))
+	return err
+}
 
-		if response.NextPageToken == "" {
-			break
+func (config Config) writeOptions() writeOptions {
+	if !config.PrettyJSON {
+		return writeOptions{}
+	}
+	return writeOptions{indent: config.IndentString}
+}
+
+// fileOptions are writeOptions for the playlist, diff and history files,
+// which keep only StoredFields and are encrypted with an encryption key.
+func (config Config) fileOptions() writeOptions {
+	options := config.writeOptions()
+	options.storedFields = config.StoredFields
+	options.encryptionKey = config.encryptionKey
+	return options
+}
+
+func (config Config) diffOptions() diffOptions {
+	return diffOptions{deletedTitles: config.DeletedTitles, membershipOnly: config.IgnoreTitleChanges, descriptions: config.TrackDescriptions}
+}
+
+// trackedOnly filters videos down to TrackOnlyVideoIds, if any.
+func (config Config) trackedOnly(videos []Video) []Video {
+	if len(config.TrackOnlyVideoIds) == 0 {
+		return videos
+	}
+	var kept []Video
+	for _, video := range videos {
+		if slices.Contains(config.TrackOnlyVideoIds, video.VideoId) {
+			kept = append(kept, video)
 		}
-		pageToken = response.NextPageToken
 	}
-	playlist := newPlaylist(videos)
-	oldPlaylist, err := readPlaylistFromFile(*config, config.PlaylistFileName)
-	oldDiff, _ := readPlaylistFromFile(*config, config.DiffFileName)
+	return kept
+}
 
-	if err != nil {
-		log.Printf("Error fetching playlist %s a new playlist will be created", err)
-		writeFile(playlist, config.DirPath, config.PlaylistFileName)
+// deduplicated drops repeated VideoIds with DeduplicateOnWrite, keeping the
+// first entry of each.
+func (config Config) deduplicated(videos []Video) []Video {
+	if !config.DeduplicateOnWrite {
+		return videos
+	}
+	seen := make(map[string]bool)
+	var kept []Video
+	for _, video := range videos {
+		if !seen[video.VideoId] {
+			seen[video.VideoId] = true
+			kept = append(kept, video)
+		}
+	}
+	if removed := len(videos) - len(kept); removed > 0 {
+		log.Printf("Removed %d duplicate videos from playlist %s", removed, config.PlaylistId)
+	}
+	return kept
+}
+
+// exportOptions are writeOptions for OutputFormats, which unlike the
+// canonical files honor JSONNaming.
+func (config Config) exportOptions() writeOptions {
+	options := config.writeOptions()
+	options.deletedTitles = config.DeletedTitles
+	options.templateFile = config.TemplateFile
+	options.snakeCase = config.JSONNaming == "snake_case"
+	options.locale, _ = findNumberLocale(config.ReportLocale)
+	return options
+}
+
+func (config Config) shrunkTooMuch(oldCount, newCount int) bool {
+	if config.ShrinkGuard <= 0 || oldCount == 0 || newCount >= oldCount {
+		return false
+	}
+	return (oldCount-newCount)*100 > oldCount*config.ShrinkGuard
+}
+
+// saveHistoryHook, when set, is called with every playlist saveHistory
+// archives, for tests.
+var saveHistoryHook func(oldPlaylist YoutubePlaylist)
+
+func (config Config) saveHistory(oldDiff YoutubePlaylist, oldPlaylist YoutubePlaylist) {
+	if saveHistoryHook != nil {
+		saveHistoryHook(oldPlaylist)
+	}
+	fileName := config.historyFileName(oldPlaylist.UpdatedAt, config.PlaylistFileName)
+	config.writeFile(&oldPlaylist, fileName)
+	if oldDiff.Playlist != nil || oldDiff.categorized() {
+		diffFileName := config.historyFileName(oldDiff.UpdatedAt, config.DiffFileName)
+		config.writeFile(&oldDiff, diffFileName)
+		os.Remove(filepath.Join(config.DirPath, config.DiffFileName))
+
+	}
+}
+
+// historyFileName names the history copy of fileName taken at t. Snapshots
+// can share an UpdatedAt with UpdatedAtSource "latestAdded", so a counter is
+// appended rather than overwriting an earlier copy.
+func (config Config) historyFileName(t time.Time, fileName string) string {
+	name := fmt.Sprintf("%s_%s", t.Format(time.RFC3339), fileName)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(config.DirPath, name)); err != nil {
+			return name
+		}
+		name = fmt.Sprintf("%s.%d_%s", t.Format(time.RFC3339), i, fileName)
+	}
+}
+
+func (config Config) clearDiff(oldDiff YoutubePlaylist) {
+	diffPath := filepath.Join(config.DirPath, config.DiffFileName)
+	if _, err := os.Stat(diffPath); err != nil {
 		return
 	}
+	if config.KeepHistory && (len(oldDiff.Playlist) > 0 || oldDiff.categorized()) {
+		diffFileName := config.historyFileName(oldDiff.UpdatedAt, config.DiffFileName)
+		config.writeFile(&oldDiff, diffFileName)
+	}
+	os.Remove(diffPath)
+}
 
-	diff := playlist.subtract(oldPlaylist)
+func (config Config) writeRemoved(changes *Changes) {
+	if config.RemovedFileName != "" {
+		config.writeFile(newPlaylist(changes.Removed), config.RemovedFileName)
+	}
+}
 
-	if diff.Playlist == nil {
-		if len(playlist.Playlist) != len(oldPlaylist.Playlist) {
-			if config.KeepHistory {
-				config.saveHistory(oldDiff, oldPlaylist)
-			}
-			writeFile(playlist, config.DirPath, config.PlaylistFileName)
-			log.Println("Only new videos were found")
-			return
-		} else {
-			log.Println("No diff and no new videos, nothing to do")
-			return
+func (config Config) writeEmptyDiff(oldDiff YoutubePlaylist) {
+	config.clearDiff(oldDiff)
+	if config.AlwaysWriteDiff {
+		config.writeFile(newPlaylist([]Video{}), config.DiffFileName)
+	}
+}
+
+// setLogPrefix labels log lines with RunName.
+func (config Config) setLogPrefix() {
+	if config.RunName != "" {
+		log.SetPrefix("[" + config.RunName + "] ")
+	}
+}
+
+func runLabel(runName string) string {
+	if runName == "" {
+		return ""
+	}
+	return " (" + runName + ")"
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
+	})
+	return set
+}
+
+func main() {
+	listFormats := flag.Bool("list-formats", false, "print the supported output formats and exit")
+	check := flag.Bool("check", false, "verify the config, credentials and DirPath without writing playlist files, then exit")
+	printConfig := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	initialize := flag.Bool("init", false, "write a commented template config.json and exit")
+	force := flag.Bool("force", false, "overwrite the playlist even when a safety check fails")
+	concurrency := flag.Int("concurrency", 0, "process this many playlists at once, overriding the config")
+	noColor := flag.Bool("no-color", false, "do not colorize the summary")
+	baselineStdin := flag.Bool("baseline-stdin", false, "read the playlist to diff against from stdin, like baselineFile \"-\"")
+	archive := flag.Bool("archive", false, "gather the history snapshots into historyArchiveFileName and exit")
+	prune := flag.Bool("prune", false, "with -archive, remove the snapshot files once archived")
+	verify := flag.Bool("verify", false, "probe each stored video's watch page without the API, write an availability report and exit")
+	migrate := flag.Bool("migrate", false, "rewrite the playlist, diff and history files in the current schema, keeping .bak copies, and exit")
+	importFile := flag.String("import", "", "write the videos of this M3U or URL list file as the playlist file to diff the first run against, and exit")
+	configDir := flag.String("config-dir", "", "run every *.json config in this directory in turn instead of config.json, except the files they include")
+	note := flag.String("note", "", "attach this note to the playlist and diff written by this run")
+	explain := flag.Bool("explain", false, "print why each playlist took the path it did at the end of the run")
+	changed := flag.Bool("changed", false, "only print whether the playlists changed, exiting 0 if they did and 1 if not, without writing files")
+	replay := flag.String("replay", "", "read API responses saved in this directory instead of calling the API")
+	fetchOnly := flag.String("fetch-only", "", "save the raw API responses in this directory, for -replay, without diffing or writing the playlist files")
+	flag.Parse()
+
+	if *listFormats {
+		printOutputFormats(os.Stdout)
+		return
+	}
+	if *initialize {
+		if err := initConfig(); err != nil {
+			log.Fatalf("Error writing config template: %v", err)
+		}
+		fmt.Println("Config template written to", configFileName)
+		return
+	}
+
+	if isFlagSet("concurrency") && *concurrency < 1 {
+		log.Fatalf("-concurrency must be at least 1, got %d", *concurrency)
 	}
 
-	if config.KeepHistory {
-		config.saveHistory(oldDiff, oldPlaylist)
+	if *check {
+		if !runChecks(os.Stdout) {
+			os.Exit(1)
+		}
+		return
 	}
 
-	writeFile(playlist, config.DirPath, config.PlaylistFileName)
-	writeFile(diff, config.DirPath, config.DiffFileName)
+	options := RunOptions{Force: *force, ReplayDir: *replay, Concurrency: *concurrency, DetectOnly: *changed, Note: *note, FetchOnlyDir: *fetchOnly}
+	if *configDir != "" {
+		if !runConfigDir(os.Stdout, *configDir, options, useColor(*noColor)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	config := newConfig()
+	config.setLogPrefix()
+	if *baselineStdin {
+		config.BaselineFile = "-"
+		if err := config.validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *archive {
+		if err := archiveHistory(config, *prune); err != nil {
+			log.Fatalf("Error archiving history: %v", err)
+		}
+		return
+	}
+	if *verify {
+		if err := verifyPlaylists(config); err != nil {
+			log.Fatalf("Error verifying videos: %v", err)
+		}
+		return
+	}
+	if *migrate {
+		if err := migrateFiles(config); err != nil {
+			log.Fatalf("Error migrating files: %v", err)
+		}
+		return
+	}
+	if *importFile != "" {
+		if err := importVideoList(config, *importFile, *force); err != nil {
+			log.Fatalf("Error importing videos: %v", err)
+		}
+		return
+	}
+	if *printConfig {
+		jsonData, err := json.MarshalIndent(config.redacted(), "", "  ")
+		if err != nil {
+			log.Fatalf("Error marshaling config: %v", err)
+		}
+		fmt.Println(string(jsonData))
+		return
+	}
+	result, err := Run(config, options)
+	if *changed {
+		if err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+		anyChanged := slices.ContainsFunc(result.Playlists, func(playlist PlaylistResult) bool { return playlist.Changed })
+		fmt.Println(anyChanged)
+		if !anyChanged {
+			os.Exit(1)
+		}
+		return
+	}
+	printSummaries(os.Stdout, result, useColor(*noColor))
+	if *explain {
+		printDecisions(os.Stdout, result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Done%s in %s with %d API calls\n", runLabel(result.RunName), result.Duration.Round(time.Millisecond), result.APICalls)
 }
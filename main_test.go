@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSubtract diffs a playlist against a copy where every tenth video
+// was removed and every tenth retitled, so the cost should scale linearly.
+func BenchmarkSubtract(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		old := testPlaylist(size)
+		current := testPlaylist(size)
+		var kept []Video
+		for i, video := range current.Playlist {
+			switch i % 10 {
+			case 0:
+				continue
+			case 1:
+				video.Title = "Deleted video"
+			}
+			kept = append(kept, video)
+		}
+		current.Playlist = kept
+		options := diffOptions{deletedTitles: defaultDeletedTitles}
+
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				current.subtract(*old, options)
+			}
+		})
+	}
+}
+
+func TestSubtractListsChangedVideosAsTheyWere(t *testing.T) {
+	old := newPlaylist([]Video{
+		{Title: "A", VideoId: "aaaaaaaaaaa", Availability: availabilityPublic},
+		{Title: "B", VideoId: "bbbbbbbbbbb", Availability: availabilityPublic},
+		{Title: "C", VideoId: "ccccccccccc", Availability: availabilityPublic},
+	})
+	current := newPlaylist([]Video{
+		{Title: "A", VideoId: "aaaaaaaaaaa", Availability: availabilityPrivate},
+		{Title: "Deleted video", VideoId: "bbbbbbbbbbb", Availability: availabilityPublic},
+	})
+
+	diff := current.subtract(*old, diffOptions{deletedTitles: defaultDeletedTitles})
+	if len(diff.Playlist) != len(old.Playlist) {
+		t.Fatalf("diff has %d videos, want %d", len(diff.Playlist), len(old.Playlist))
+	}
+	for i, video := range diff.Playlist {
+		if video != old.Playlist[i] {
+			t.Errorf("diff lists %+v, want the old snapshot %+v", video, old.Playlist[i])
+		}
+	}
+}
+
+func TestTolerateReadOnlyDirPathNeedsAnotherSink(t *testing.T) {
+	config := testConfig(t, nil)
+	config.TolerateReadOnlyDirPath = true
+	if err := config.validate(); err == nil {
+		t.Error("tolerateReadOnlyDirPath without publishBroker validated")
+	}
+	config.PublishBroker, config.PublishAddr, config.PublishChannel = "redis", "localhost:6379", "playlists"
+	if err := config.validate(); err != nil {
+		t.Errorf("tolerateReadOnlyDirPath with publishBroker: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	availabilityPublic  = "public"
+	availabilityPrivate = "private"
+	availabilityDeleted = "deleted"
+	availabilityBlocked = "blocked"
+)
+
+// The videos endpoint accepts at most 50 ids per request.
+const videosBatchSize = 50
+
+type VideosResponse struct {
+	Items []VideoDetails `json:"items"`
+}
+
+type VideoDetails struct {
+	Id             string         `json:"id"`
+	Status         VideoStatus    `json:"status"`
+	ContentDetails ContentDetails `json:"contentDetails"`
+}
+
+type VideoStatus struct {
+	PrivacyStatus string `json:"privacyStatus"`
+}
+
+type ContentDetails struct {
+	Duration          string             `json:"duration"`
+	RegionRestriction *RegionRestriction `json:"regionRestriction"`
+}
+
+type RegionRestriction struct {
+	Allowed []string `json:"allowed"`
+	Blocked []string `json:"blocked"`
+}
+
+func fetchVideoDetails(client *apiClient, videoIds []string) (*VideosResponse, error) {
+	params := url.Values{}
+	params.Set("part", "status,contentDetails")
+	params.Set("maxResults", "50")
+	params.Set("id", strings.Join(videoIds, ","))
+
+	var response VideosResponse
+	if err := client.getJSON("videos", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (details VideoDetails) availability(regionCode string) string {
+	if details.Status.PrivacyStatus == "private" {
+		return availabilityPrivate
+	}
+	if restriction := details.ContentDetails.RegionRestriction; restriction != nil {
+		if regionCode == "" && (len(restriction.Allowed) > 0 || len(restriction.Blocked) > 0) {
+			return availabilityBlocked
+		}
+		if slices.Contains(restriction.Blocked, regionCode) {
+			return availabilityBlocked
+		}
+		if len(restriction.Allowed) > 0 && !slices.Contains(restriction.Allowed, regionCode) {
+			return availabilityBlocked
+		}
+	}
+	return availabilityPublic
+}
+
+// missingAvailability classifies a video the videos endpoint did not return,
+// which happens for both private and deleted videos.
+func missingAvailability(video *Video) {
+	if video.Title == "Private video" {
+		video.Availability = availabilityPrivate
+	} else {
+		video.Availability = availabilityDeleted
+	}
+}
+
+// videoEnricher fetches metadata for pages of videos in the background while
+// pagination continues, writing results back through the accumulator.
+type videoEnricher struct {
+	config *Config
+	client *apiClient
+	videos *videoAccumulator
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    error
+}
+
+func newVideoEnricher(config *Config, client *apiClient, videos *videoAccumulator) *videoEnricher {
+	return &videoEnricher{config: config, client: client, videos: videos}
+}
+
+func (enricher *videoEnricher) enrich(videoIds []string) {
+	for start := 0; start < len(videoIds); start += videosBatchSize {
+		batch := videoIds[start:min(start+videosBatchSize, len(videoIds))]
+		enricher.wg.Add(1)
+		go func() {
+			defer enricher.wg.Done()
+			if err := enricher.enrichBatch(batch); err != nil {
+				enricher.mu.Lock()
+				if enricher.err == nil {
+					enricher.err = err
+				}
+				enricher.mu.Unlock()
+			}
+		}()
+	}
+}
+
+func (enricher *videoEnricher) enrichBatch(videoIds []string) error {
+	response, err := fetchVideoDetails(enricher.client, videoIds)
+	if err != nil {
+		return err
+	}
+
+	returned := make(map[string]bool)
+	for _, details := range response.Items {
+		returned[details.Id] = true
+		availability := details.availability(enricher.config.RegionCode)
+		duration, err := parseISODuration(details.ContentDetails.Duration)
+		if err != nil && details.ContentDetails.Duration != "" {
+			log.Printf("WARNING: video %s: %v", details.Id, err)
+		}
+		enricher.videos.update(details.Id, func(video *Video) {
+			video.Availability = availability
+			video.Duration = details.ContentDetails.Duration
+			// Live streams and premieres report a zero duration.
+			video.IsShort = duration > 0 && duration <= shortMaxDuration
+		})
+	}
+	for _, videoId := range videoIds {
+		if !returned[videoId] {
+			enricher.videos.update(videoId, missingAvailability)
+		}
+	}
+	return nil
+}
+
+func (enricher *videoEnricher) wait() error {
+	enricher.wg.Wait()
+	return enricher.err
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestRunWritesPlaylistWhenVideosEndpointFails(t *testing.T) {
+	videos := testPlaylist(3).Playlist
+	api := &fakeAPI{pages: []PlaylistItemsResponse{replayPage(videos)}, videosStatus: 500}
+	serveFakeAPI(t, api)
+
+	config := testConfig(t, map[string]any{"fetchMetadata": true, "maxRetries": 2, "retryBaseDelay": "1ms"})
+	if _, err := Run(config, RunOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := api.videosCalls.Load(); calls != 3 {
+		t.Errorf("made %d videos calls, want the first and 2 retries", calls)
+	}
+	written, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	if err != nil {
+		t.Fatalf("playlist was not written: %v", err)
+	}
+	if len(written.Playlist) != len(videos) {
+		t.Fatalf("%s has %d videos, want %d", config.PlaylistFileName, len(written.Playlist), len(videos))
+	}
+	for _, video := range written.Playlist {
+		if video.Availability != "" {
+			t.Errorf("video %s has availability %q without metadata", video.VideoId, video.Availability)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// currentSchemaVersion is the SchemaVersion of the files this version writes.
+// Files written before versioning have none, which reads as version 0.
+const currentSchemaVersion = 1
+
+// migrations[i] upgrades a playlist from schema version i to i+1.
+var migrations = []func(playlist *YoutubePlaylist){
+	// Version 0 files may store AddedToPlaylistAt as publishedAt, which
+	// Video.UnmarshalJSON already maps, and may predate Position, which is
+	// then taken from the order of the videos.
+	func(playlist *YoutubePlaylist) {
+		if slices.ContainsFunc(playlist.Playlist, func(video Video) bool { return video.Position != 0 }) {
+			return
+		}
+		for i := range playlist.Playlist {
+			playlist.Playlist[i].Position = i
+		}
+	},
+}
+
+// migratePlaylist upgrades playlist to currentSchemaVersion and reports
+// whether anything was migrated. Files from newer versions are left alone.
+func migratePlaylist(playlist *YoutubePlaylist) bool {
+	if playlist.SchemaVersion >= currentSchemaVersion {
+		return false
+	}
+	for _, migration := range migrations[playlist.SchemaVersion:] {
+		migration(playlist)
+	}
+	playlist.SchemaVersion = currentSchemaVersion
+	return true
+}
+
+// migrateFiles rewrites the playlist, diff and history files of every
+// configured playlist in the current schema. Each original is first copied
+// to a .bak file next to it.
+func migrateFiles(config *Config) error {
+	lock, err := acquireLock(config)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	configs, err := config.playlistConfigs()
+	if err != nil {
+		return err
+	}
+
+	migrated := make(map[string]bool)
+	for _, config := range configs {
+		for _, fileName := range []string{config.PlaylistFileName, config.DiffFileName} {
+			// History files are the same names with a timestamp prefix.
+			paths, err := filepath.Glob(filepath.Join(config.DirPath, "*"+fileName))
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				if migrated[path] {
+					continue
+				}
+				migrated[path] = true
+				if err := config.migrateFile(path); err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (config Config) migrateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	playlist, err := config.unmarshalPlaylist(data)
+	if err != nil {
+		return err
+	}
+	if !migratePlaylist(&playlist) {
+		fmt.Println(path, "is already at schema version", playlist.SchemaVersion)
+		return nil
+	}
+
+	config.DirPath = filepath.Dir(path)
+	if err := config.backupFile(filepath.Base(path)); err != nil {
+		return fmt.Errorf("error backing up: %w", err)
+	}
+	config.writeFile(&playlist, filepath.Base(path))
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+type Move struct {
+	Video       Video `json:"video"`
+	OldPosition int   `json:"oldPosition"`
+	NewPosition int   `json:"newPosition"`
+}
+
+// detectMoves reports the videos that were actually relocated between old and
+// current. Inserting or removing one video shifts the position of every video
+// after it, so rather than comparing positions it keeps the longest run of
+// videos whose relative order is unchanged and reports only the rest.
+func detectMoves(old, current YoutubePlaylist) []Move {
+	oldVideos := inPlaylistOrder(old.Playlist)
+	currentVideos := inPlaylistOrder(current.Playlist)
+
+	currentIndex := make(map[string]int)
+	for _, video := range currentVideos {
+		currentIndex[video.VideoId] = len(currentIndex)
+	}
+	var common []Video
+	for _, video := range oldVideos {
+		if _, found := currentIndex[video.VideoId]; found {
+			common = append(common, video)
+		}
+	}
+
+	sequence := make([]int, len(common))
+	for i, video := range common {
+		sequence[i] = currentIndex[video.VideoId]
+	}
+	stable := longestIncreasing(sequence)
+
+	var moves []Move
+	for i, video := range common {
+		if !stable[i] {
+			moved := currentVideos[currentIndex[video.VideoId]]
+			moves = append(moves, Move{Video: moved, OldPosition: video.Position, NewPosition: moved.Position})
+		}
+	}
+	slices.SortFunc(moves, func(a, b Move) int {
+		return cmp.Compare(a.NewPosition, b.NewPosition)
+	})
+	return moves
+}
+
+// inPlaylistOrder orders videos by Position, since files may be written with
+// another SortBy, and keeps only the first entry per VideoId.
+func inPlaylistOrder(videos []Video) []Video {
+	ordered := slices.Clone(videos)
+	slices.SortStableFunc(ordered, func(a, b Video) int {
+		return cmp.Compare(a.Position, b.Position)
+	})
+
+	seen := make(map[string]bool)
+	unique := ordered[:0]
+	for _, video := range ordered {
+		if !seen[video.VideoId] {
+			seen[video.VideoId] = true
+			unique = append(unique, video)
+		}
+	}
+	return unique
+}
+
+// longestIncreasing marks the elements of one longest strictly increasing
+// subsequence of sequence, in O(n log n).
+func longestIncreasing(sequence []int) []bool {
+	// tails[k] is the index of the smallest tail of an increasing run of
+	// length k+1 seen so far; previous links each element to its predecessor.
+	var tails []int
+	previous := make([]int, len(sequence))
+	for i, value := range sequence {
+		k := sort.Search(len(tails), func(j int) bool {
+			return sequence[tails[j]] >= value
+		})
+		if k > 0 {
+			previous[i] = tails[k-1]
+		} else {
+			previous[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	stable := make([]bool, len(sequence))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			stable[i] = true
+		}
+	}
+	return stable
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// snakeCase converts a camelCase JSON key such as "addedToPlaylistAt" or
+// "prettyJSON" to "added_to_playlist_at" or "pretty_json".
+func snakeCase(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// renameKeys rewrites every object key in data with rename, keeping the
+// original key order, and returns compact JSON.
+func renameKeys(data []byte, rename func(string) string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	type container struct {
+		object bool
+		count  int
+	}
+	var stack []container
+	var out bytes.Buffer
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return out.Bytes(), nil
+			}
+			return nil, err
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			out.WriteRune(rune(delim))
+			continue
+		}
+
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.object && top.count%2 == 0 {
+				if top.count > 0 {
+					out.WriteByte(',')
+				}
+				key, _ := json.Marshal(rename(token.(string)))
+				out.Write(key)
+				out.WriteByte(':')
+				top.count++
+				continue
+			}
+			if !top.object && top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.count++
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			out.WriteRune(rune(value))
+			stack = append(stack, container{object: value == '{'})
+		case json.Number:
+			out.WriteString(value.String())
+		case nil:
+			out.WriteString("null")
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+		}
+	}
+}
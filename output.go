@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// outputFormat renders either a playlist or the changes since the previous
+// one, so exactly one of write and writeChanges is set.
+type outputFormat struct {
+	name         string
+	description  string
+	extension    string
+	write        func(w io.Writer, playlist *YoutubePlaylist, options writeOptions) error
+	writeChanges func(w io.Writer, changes *Changes, options writeOptions) error
+}
+
+type writeOptions struct {
+	// indent is the JSON indentation per level; empty writes compact JSON.
+	indent string
+	// snakeCase renames JSON keys to snake_case, for exports only since the
+	// canonical files must stay readable by readPlaylistFromFile.
+	snakeCase bool
+	// deletedTitles are the titles that mark a video as deleted.
+	deletedTitles []string
+	// templateFile is the text/template the template format executes.
+	templateFile string
+	// locale formats the counts and durations of the template format.
+	locale numberLocale
+	// storedFields are the video fields the json format keeps.
+	storedFields storedFields
+	// encryptionKey, when set, encrypts the output.
+	encryptionKey []byte
+}
+
+var defaultWriteOptions = writeOptions{indent: "  ", deletedTitles: defaultDeletedTitles}
+
+var outputFormats = []outputFormat{
+	{name: "json", description: "JSON, used for the playlist, diff and history files", extension: ".json", write: writeJSON},
+	{name: "urls", description: "one watch URL per line, skipping deleted and private videos", extension: ".txt", write: writeURLs},
+	{name: "template", description: "the Go text/template in templateFile, executed with the playlist", extension: ".txt", write: writeTemplate},
+	{name: "changes", description: "JSON object of added, removed, renamed and moved videos", extension: ".changes.json", writeChanges: writeChangesJSON},
+	{name: "changelog", description: "markdown list of added, removed, renamed and moved videos", extension: ".md", writeChanges: writeChangelog},
+	{name: "unified", description: "unified diff with a \"VideoId  Title\" line per added, removed or renamed video", extension: ".diff", writeChanges: writeUnified},
+}
+
+func findOutputFormat(name string) (outputFormat, bool) {
+	for _, format := range outputFormats {
+		if format.name == name {
+			return format, true
+		}
+	}
+	return outputFormat{}, false
+}
+
+// WritePlaylist serializes playlist to w in the named output format.
+func WritePlaylist(w io.Writer, playlist *YoutubePlaylist, format string) error {
+	return writePlaylist(w, playlist, format, defaultWriteOptions)
+}
+
+// writePlaylist is WritePlaylist with options, such as the configured ones
+// writeFile uses.
+func writePlaylist(w io.Writer, playlist *YoutubePlaylist, format string, options writeOptions) error {
+	outputFormat, found := findOutputFormat(format)
+	if !found {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if outputFormat.write == nil {
+		return fmt.Errorf("output format %q renders changes, not playlists", format)
+	}
+	return encrypting(w, options.encryptionKey, func(w io.Writer) error {
+		return outputFormat.write(w, playlist, options)
+	})
+}
+
+// WriteChanges renders changes to w in the named output format.
+func WriteChanges(w io.Writer, changes *Changes, format string) error {
+	return writeChanges(w, changes, format, defaultWriteOptions)
+}
+
+// writeChanges is WriteChanges with options.
+func writeChanges(w io.Writer, changes *Changes, format string, options writeOptions) error {
+	outputFormat, found := findOutputFormat(format)
+	if !found {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if outputFormat.writeChanges == nil {
+		return fmt.Errorf("output format %q renders playlists, not changes", format)
+	}
+	return encrypting(w, options.encryptionKey, func(w io.Writer) error {
+		return outputFormat.writeChanges(w, changes, options)
+	})
+}
+
+func printOutputFormats(w io.Writer) {
+	for _, format := range outputFormats {
+		fmt.Fprintf(w, "%-10s %s\n", format.name, format.description)
+	}
+}
+
+// writeJSON writes the same JSON as encodeJSON, one video at a time, so a
+// slow or closed pipe holds up or stops the write rather than the whole
+// playlist being encoded first. The first write error is returned. With
+// storedFields, or for a categorized diff, the playlist is encoded as
+// stored instead.
+func writeJSON(w io.Writer, playlist *YoutubePlaylist, options writeOptions) error {
+	if len(options.storedFields) > 0 || playlist.categorized() {
+		data, err := options.storedFields.encode(playlist)
+		if err != nil {
+			return err
+		}
+		return encodeJSON(w, data, options)
+	}
+	if len(playlist.Playlist) == 0 {
+		return encodeJSON(w, playlist, options)
+	}
+
+	rest := *playlist
+	rest.Playlist = nil
+	var envelope bytes.Buffer
+	if err := encodeJSON(&envelope, rest, options); err != nil {
+		return err
+	}
+	videosKey := []byte(`"videos":null`)
+	if options.indent != "" {
+		videosKey = []byte(`"videos": null`)
+	}
+	head, tail, found := bytes.Cut(envelope.Bytes(), videosKey)
+	if !found {
+		return encodeJSON(w, playlist, options)
+	}
+
+	out := bufio.NewWriter(w)
+	out.Write(head)
+	out.Write(videosKey[:len(videosKey)-len("null")])
+	out.WriteByte('[')
+	elementOptions := options
+	elementOptions.indent = ""
+	prefix := strings.Repeat(options.indent, 2)
+	for i, video := range playlist.Playlist {
+		var element bytes.Buffer
+		if i > 0 {
+			element.WriteByte(',')
+		}
+		if options.indent != "" {
+			element.WriteString("\n" + prefix)
+		}
+		var compact bytes.Buffer
+		if err := encodeJSON(&compact, video, elementOptions); err != nil {
+			return err
+		}
+		if options.indent == "" {
+			element.Write(compact.Bytes())
+		} else if err := json.Indent(&element, compact.Bytes(), prefix, options.indent); err != nil {
+			return err
+		}
+		// bufio passes each full buffer on as it goes and keeps the first
+		// error, such as EPIPE from a consumer that went away.
+		if _, err := out.Write(element.Bytes()); err != nil {
+			return err
+		}
+	}
+	if options.indent != "" {
+		out.WriteString("\n" + options.indent)
+	}
+	out.WriteByte(']')
+	out.Write(tail)
+	return out.Flush()
+}
+
+func writeChangesJSON(w io.Writer, changes *Changes, options writeOptions) error {
+	return encodeJSON(w, changes, options)
+}
+
+func encodeJSON(w io.Writer, v any, options writeOptions) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if options.snakeCase {
+		jsonData, err = renameKeys(jsonData, snakeCase)
+		if err != nil {
+			return err
+		}
+	}
+	if options.indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, jsonData, "", options.indent); err != nil {
+			return err
+		}
+		jsonData = indented.Bytes()
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func unavailable(video Video, deletedTitles []string) bool {
+	switch video.Availability {
+	case availabilityDeleted, availabilityPrivate:
+		return true
+	}
+	return video.Title == "Private video" || slices.Contains(deletedTitles, video.Title)
+}
+
+func writeURLs(w io.Writer, playlist *YoutubePlaylist, options writeOptions) error {
+	out := bufio.NewWriter(w)
+	for _, video := range playlist.Playlist {
+		if !unavailable(video, options.deletedTitles) {
+			fmt.Fprintln(out, watchURL(video.VideoId))
+		}
+	}
+	return out.Flush()
+}
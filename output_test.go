@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testPlaylist(n int) *YoutubePlaylist {
+	videos := make([]Video, n)
+	for i := range videos {
+		videos[i] = Video{Title: fmt.Sprintf("Video %d", i), VideoId: fmt.Sprintf("video%06d", i), Position: i,
+			AddedToPlaylistAt: time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC), ChannelTitle: "Channel"}
+	}
+	playlist := newPlaylist(videos)
+	playlist.ItemCount = n
+	return playlist
+}
+
+func TestWriteJSONMatchesEncodeJSON(t *testing.T) {
+	for _, options := range []writeOptions{
+		defaultWriteOptions,
+		{},
+		{indent: "\t", snakeCase: true},
+	} {
+		for _, playlist := range []*YoutubePlaylist{testPlaylist(0), testPlaylist(1), testPlaylist(3)} {
+			var streamed, encoded bytes.Buffer
+			if err := writeJSON(&streamed, playlist, options); err != nil {
+				t.Fatal(err)
+			}
+			if err := encodeJSON(&encoded, playlist, options); err != nil {
+				t.Fatal(err)
+			}
+			if streamed.String() != encoded.String() {
+				t.Errorf("writeJSON with %d videos and indent %q wrote\n%s\nwant\n%s", len(playlist.Playlist), options.indent, &streamed, &encoded)
+			}
+		}
+	}
+}
+
+func TestWriteJSONStopsWhenReaderCloses(t *testing.T) {
+	reader, writer := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- writeJSON(writer, testPlaylist(100000), defaultWriteOptions)
+	}()
+
+	buffer := make([]byte, 1024)
+	for range 4 {
+		if _, err := reader.Read(buffer); err != nil {
+			break
+		}
+	}
+	reader.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("writeJSON returned %v, want %v", err, io.ErrClosedPipe)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("writeJSON did not stop after the reader closed")
+	}
+}
+
+func TestWriteFileHonorsConfig(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, bytes.Repeat([]byte("ab"), 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig(t, map[string]any{"prettyJSON": false, "storedFields": []string{"title"}, "encryptionKeyFile": keyFile})
+	config.writeFile(testPlaylist(2), config.PlaylistFileName)
+
+	data, err := os.ReadFile(filepath.Join(config.DirPath, config.PlaylistFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) {
+		t.Fatalf("%s is not encrypted", config.PlaylistFileName)
+	}
+	plaintext, err := decrypt(config.encryptionKey, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.ContainsAny(plaintext, "\n\t") || bytes.Contains(plaintext, []byte("channelTitle")) {
+		t.Errorf("wrote %s, want compact JSON without channelTitle", plaintext)
+	}
+	written, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Playlist) != 2 || written.Playlist[1].Title != "Video 1" {
+		t.Errorf("read back %v, want the 2 videos with their titles", written.Playlist)
+	}
+}
+
+func TestWritePlaylistMatchesWriteFile(t *testing.T) {
+	videos := testPlaylist(3).Playlist
+	diff := newPlaylist(nil)
+	diff.categorize(&Changes{Added: videos[:2], Removed: videos[2:]}, videos[:2], defaultDeletedTitles)
+	config := testConfig(t, nil)
+	config.writeFile(diff, config.DiffFileName)
+
+	written, err := os.ReadFile(filepath.Join(config.DirPath, config.DiffFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed bytes.Buffer
+	if err := WritePlaylist(&streamed, diff, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != string(written) {
+		t.Errorf("WritePlaylist wrote\n%s\nwant what writeFile stored\n%s", &streamed, written)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+)
+
+// pageSizes are the maxResults values adaptive paging steps through, from
+// the API maximum down to what still completes on a poor connection.
+var pageSizes = []int{50, 25, 10}
+
+// Successful pages needed before stepping back up to a larger page size.
+const pageSizeRecovery = 3
+
+type pageSizer struct {
+	adaptive  bool
+	level     int
+	successes int
+}
+
+func (sizer *pageSizer) size() int {
+	return pageSizes[sizer.level]
+}
+
+// failed reports whether the page should be retried at a smaller size.
+func (sizer *pageSizer) failed(err error) bool {
+	var netErr net.Error
+	if !sizer.adaptive || !errors.As(err, &netErr) || !netErr.Timeout() || sizer.level == len(pageSizes)-1 {
+		return false
+	}
+	sizer.level++
+	sizer.successes = 0
+	log.Printf("Request timed out, retrying with %d results per page", sizer.size())
+	return true
+}
+
+func (sizer *pageSizer) succeeded() {
+	if sizer.level == 0 {
+		return
+	}
+	sizer.successes++
+	if sizer.successes >= pageSizeRecovery {
+		sizer.level--
+		sizer.successes = 0
+		log.Printf("Requests are succeeding again, using %d results per page", sizer.size())
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type PlaylistConfig struct {
+	PlaylistId string `json:"playlistId"`
+	// Alias is a friendly name used instead of PlaylistId for the playlist's
+	// subdirectory or file name prefix.
+	Alias string `json:"alias"`
+	// MaxVideos stops fetching once this many videos, in API order, were
+	// collected. Zero tracks the whole playlist.
+	MaxVideos int `json:"maxVideos"`
+}
+
+func (playlist PlaylistConfig) name() string {
+	if playlist.Alias != "" {
+		return playlist.Alias
+	}
+	return playlist.PlaylistId
+}
+
+func (playlist PlaylistConfig) validate() error {
+	if playlist.PlaylistId == "" {
+		return fmt.Errorf("playlist %q has no playlistId", playlist.Alias)
+	}
+	name := playlist.name()
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("playlist name %q cannot be used as a file name", name)
+	}
+	if playlist.MaxVideos < 0 {
+		return fmt.Errorf("playlist %s: maxVideos must not be negative", name)
+	}
+	return nil
+}
+
+// forPlaylist derives the config for one entry of Playlists. Its files go in
+// a subdirectory of DirPath with PlaylistSubdirectories, and are otherwise
+// prefixed with the playlist name so playlists do not overwrite each other.
+func (config Config) forPlaylist(playlist PlaylistConfig) (*Config, error) {
+	config.PlaylistId = playlist.PlaylistId
+	config.Playlists = nil
+	config.maxVideos = playlist.MaxVideos
+	if config.StrmDir != "" {
+		config.StrmDir = filepath.Join(config.StrmDir, playlist.name())
+	}
+
+	name := playlist.name()
+	if config.PlaylistSubdirectories {
+		config.DirPath = filepath.Join(config.DirPath, name)
+		if err := os.MkdirAll(config.DirPath, 0755); err != nil {
+			return nil, fmt.Errorf("error creating playlist directory: %w", err)
+		}
+	} else {
+		config.PlaylistFileName = name + "_" + config.PlaylistFileName
+		config.DiffFileName = name + "_" + config.DiffFileName
+		if config.RemovedFileName != "" {
+			config.RemovedFileName = name + "_" + config.RemovedFileName
+		}
+		config.HistoryArchiveFileName = name + "_" + config.HistoryArchiveFileName
+		if config.CountsFile != "" {
+			config.CountsFile = name + "_" + config.CountsFile
+		}
+	}
+
+	return &config, nil
+}
+
+// playlistConfigs returns the config of every configured playlist, which is
+// config itself outside multi-playlist mode.
+func (config *Config) playlistConfigs() ([]*Config, error) {
+	if len(config.Playlists) == 0 {
+		return []*Config{config}, nil
+	}
+	var configs []*Config
+	for _, playlist := range config.Playlists {
+		playlistConfig, err := config.forPlaylist(playlist)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, playlistConfig)
+	}
+	return configs, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+const publishTimeout = 10 * time.Second
+
+// publisher sends a diff to a message broker channel.
+type publisher interface {
+	publish(channel string, payload []byte) error
+}
+
+// newPublisher returns the publisher for PublishBroker, or nil when diffs are
+// not published.
+func newPublisher(config *Config) (publisher, error) {
+	switch config.PublishBroker {
+	case "":
+		return nil, nil
+	case "redis":
+		return &redisPublisher{addr: config.PublishAddr, password: config.PublishPassword}, nil
+	}
+	return nil, fmt.Errorf("publishBroker must be \"redis\", got %q", config.PublishBroker)
+}
+
+// redisPublisher publishes with the Redis PUBLISH command, on a connection per
+// message since runs publish rarely.
+type redisPublisher struct {
+	addr     string
+	password string
+}
+
+func (p *redisPublisher) publish(channel string, payload []byte) error {
+	conn, err := net.DialTimeout("tcp", p.addr, publishTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(publishTimeout))
+
+	reader := bufio.NewReader(conn)
+	if p.password != "" {
+		if err := redisCommand(conn, reader, []byte("AUTH"), []byte(p.password)); err != nil {
+			return fmt.Errorf("error authenticating: %w", err)
+		}
+	}
+	return redisCommand(conn, reader, []byte("PUBLISH"), []byte(channel), payload)
+}
+
+// redisCommand sends a command in the RESP protocol and checks its reply.
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...[]byte) error {
+	var command bytes.Buffer
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write(command.Bytes()); err != nil {
+		return err
+	}
+
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(reply, "-") {
+		return errors.New(strings.TrimSpace(reply[1:]))
+	}
+	return nil
+}
+
+// publishedChanges is the message published for each playlist with changes.
+type publishedChanges struct {
+	RunName    string   `json:"runName,omitempty"`
+	Name       string   `json:"name"`
+	PlaylistId string   `json:"playlistId"`
+	Changes    *Changes `json:"changes"`
+}
+
+// publishChanges publishes the changes of every playlist that changed to
+// PublishChannel, skipping unchanged diffs unless RenotifyUnchanged is set. A
+// failure is only a warning, the files are already written.
+func (config Config) publishChanges(publisher publisher, results []PlaylistResult) {
+	options := config.exportOptions()
+	options.indent = ""
+	for _, result := range results {
+		if result.Changes == nil || result.Changes.empty() {
+			continue
+		}
+		if result.DiffUnchanged && !config.RenotifyUnchanged {
+			continue
+		}
+		var payload bytes.Buffer
+		message := publishedChanges{RunName: config.RunName, Name: result.Name, PlaylistId: result.PlaylistId, Changes: result.Changes}
+		if err := encodeJSON(&payload, message, options); err != nil {
+			log.Printf("WARNING: error encoding changes of playlist %s: %v", result.Name, err)
+			continue
+		}
+		if err := publisher.publish(config.PublishChannel, payload.Bytes()); err != nil {
+			log.Printf("WARNING: error publishing changes of playlist %s: %v", result.Name, err)
+		}
+	}
+}
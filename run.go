@@ -0,0 +1,645 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RunOptions struct {
+	// Force overwrites the playlist even when a safety check fails.
+	Force bool
+	// ReplayDir reads playlistItems pages from saved API responses instead
+	// of calling the API, see replayFileName.
+	ReplayDir string
+	// Concurrency overrides Config.Concurrency when positive.
+	Concurrency int
+	// DetectOnly fetches and diffs playlists without writing any file, to
+	// only fill in PlaylistResult.Changed.
+	DetectOnly bool
+	// Note is attached to the files written, overriding Config.NotesFile.
+	Note string
+	// FetchOnlyDir saves the raw playlistItems responses in this directory,
+	// named as ReplayDir expects, and stops before diffing or writing any
+	// playlist file. It needs a single PlaylistId.
+	FetchOnlyDir string
+	// OnChange, when set, is called with every added, removed and renamed
+	// video once the playlists are processed, one call at a time. It is not
+	// called with DetectOnly.
+	OnChange func(change Change)
+}
+
+// writesFiles reports whether the run updates the stored files.
+func (options RunOptions) writesFiles() bool {
+	return !options.DetectOnly && options.FetchOnlyDir == ""
+}
+
+// RunResult reports what a Run did, for the end-of-run summary, -explain,
+// -changed and the -config-dir report.
+type RunResult struct {
+	RunName   string           `json:"runName,omitempty"`
+	Playlists []PlaylistResult `json:"playlists"`
+	Duration  time.Duration    `json:"duration"`
+	// APICalls counts HTTP requests made to the YouTube API.
+	APICalls int64 `json:"apiCalls"`
+}
+
+type PlaylistResult struct {
+	Name         string `json:"name"`
+	PlaylistId   string `json:"playlistId"`
+	Fetched      int    `json:"fetched"`
+	Added        int    `json:"added"`
+	Removed      int    `json:"removed"`
+	Renamed      int    `json:"renamed"`
+	HistorySaved bool   `json:"historySaved"`
+	// Changed reports whether anything differs from the stored playlist.
+	Changed bool `json:"changed"`
+	// DiffUnchanged is set when the diff reported the same changes as the
+	// stored one, or with IncrementalDiff only changes it already reported.
+	// The stored diff is then kept as it is.
+	DiffUnchanged bool `json:"diffUnchanged,omitempty"`
+	// Skipped is set for private playlists skipped in multi-playlist mode,
+	// and for playlists a resumed run had already completed.
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Changes is nil when the playlist could not be compared.
+	Changes *Changes `json:"-"`
+	// Decisions traces the path the run took, for -explain.
+	Decisions []string `json:"decisions,omitempty"`
+}
+
+func (result *PlaylistResult) explain(format string, args ...any) {
+	result.Decisions = append(result.Decisions, fmt.Sprintf(format, args...))
+}
+
+func (result *PlaylistResult) setChanges(changes *Changes) {
+	result.Changes = changes
+	result.Changed = result.Changed || !changes.empty()
+	result.Added = len(changes.Added)
+	result.Removed = len(changes.Removed)
+	result.Renamed = len(changes.Renamed)
+}
+
+// Run fetches the configured playlists, diffs each against the stored one and
+// updates the files in config.DirPath. In multi-playlist mode a failing
+// playlist does not stop the others, private playlists are skipped with a
+// warning, videos moved between playlists are written to cross_moves.json
+// and every playlist is summarized in index.json.
+func Run(config *Config, options RunOptions) (result RunResult, err error) {
+	start := time.Now()
+	result.RunName = config.RunName
+
+	lock, err := acquireLock(config)
+	if err != nil {
+		return result, err
+	}
+	defer lock.release()
+
+	client, err := newAPIClient(config)
+	if err != nil {
+		return result, fmt.Errorf("error creating API client: %w", err)
+	}
+	if options.ReplayDir != "" && len(config.Playlists) > 0 {
+		return result, errors.New("replay needs a single playlistId, the saved pages are not per playlist")
+	}
+	client.replayDir = options.ReplayDir
+	if options.FetchOnlyDir != "" {
+		if len(config.Playlists) > 0 {
+			return result, errors.New("fetch-only needs a single playlistId, the saved pages of several playlists would overwrite each other")
+		}
+		if err := os.MkdirAll(options.FetchOnlyDir, 0755); err != nil {
+			return result, fmt.Errorf("error creating fetch-only directory: %w", err)
+		}
+		client.saveDir = options.FetchOnlyDir
+	}
+	if options.Note == "" && config.NotesFile != "" {
+		note, err := os.ReadFile(config.NotesFile)
+		if err != nil {
+			return result, fmt.Errorf("error reading notesFile: %w", err)
+		}
+		options.Note = strings.TrimSpace(string(note))
+	}
+	publisher, err := newPublisher(config)
+	if err != nil {
+		return result, err
+	}
+	if publisher != nil && options.writesFiles() {
+		defer func() { config.publishChanges(publisher, result.Playlists) }()
+	}
+	if config.Syslog && options.writesFiles() {
+		defer func() { config.logChangesToSyslog(result.Playlists) }()
+	}
+	if options.OnChange != nil && options.writesFiles() {
+		defer func() {
+			for _, playlist := range result.Playlists {
+				if playlist.Changes != nil {
+					playlist.Changes.each(playlist.Name, options.OnChange)
+				}
+			}
+		}()
+	}
+	if config.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		client.ctx, cancel = context.WithTimeout(client.ctx, time.Duration(config.RunTimeout))
+		defer cancel()
+	}
+	defer func() {
+		result.Duration = time.Since(start)
+		result.APICalls = client.calls.Load()
+	}()
+
+	if len(config.Playlists) == 0 {
+		result.Playlists = []PlaylistResult{{Name: config.sourceName(), PlaylistId: config.PlaylistId}}
+		err := runPlaylist(config, client, options, &result.Playlists[0])
+		if err != nil {
+			result.Playlists[0].Error = err.Error()
+		}
+		return result, err
+	}
+
+	concurrency := config.Concurrency
+	if options.Concurrency > 0 {
+		concurrency = options.Concurrency
+	}
+
+	var resumed *checkpoint
+	if !options.DetectOnly {
+		resumed = loadCheckpoint(config)
+	}
+	result.Playlists = make([]PlaylistResult, len(config.Playlists))
+	errs := make([]error, len(config.Playlists))
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, playlist := range config.Playlists {
+		if resumed.done(playlist.name()) {
+			log.Printf("Skipping playlist %s, completed by the interrupted run", playlist.name())
+			result.Playlists[i] = PlaylistResult{Name: playlist.name(), PlaylistId: playlist.PlaylistId, Skipped: true}
+			continue
+		}
+		slots <- struct{}{}
+		if i > 0 && config.InterPlaylistDelay > 0 {
+			time.Sleep(time.Duration(config.InterPlaylistDelay))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result.Playlists[i] = PlaylistResult{Name: playlist.name(), PlaylistId: playlist.PlaylistId}
+			errs[i] = runListedPlaylist(config, client, playlist, options, &result.Playlists[i])
+			if errs[i] == nil {
+				resumed.complete(playlist.name())
+			}
+		}()
+	}
+	wg.Wait()
+	// Playlists that failed on their own are fetched again by the next run
+	// anyway, so only a run cut short by RunTimeout keeps the checkpoint.
+	if client.ctx.Err() == nil {
+		resumed.clear()
+	}
+	if !options.DetectOnly {
+		config.writeCrossMoves(detectCrossMoves(result.Playlists))
+		config.writeIndex(result.Playlists)
+	}
+	return result, errors.Join(errs...)
+}
+
+func runListedPlaylist(config *Config, client *apiClient, playlist PlaylistConfig, options RunOptions, result *PlaylistResult) error {
+	playlistConfig, err := config.forPlaylist(playlist)
+	if err == nil {
+		err = runPlaylist(playlistConfig, client, options, result)
+	}
+	if errors.Is(err, ErrPlaylistPrivate) {
+		log.Printf("WARNING: skipping playlist %s: %v", playlist.name(), err)
+		result.Skipped = true
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error processing playlist %s: %v", playlist.name(), err)
+		result.Error = err.Error()
+		return fmt.Errorf("playlist %s: %w", playlist.name(), err)
+	}
+	return nil
+}
+
+// runPlaylist updates the files of a single playlist, recording what it did
+// in result.
+func runPlaylist(config *Config, client *apiClient, options RunOptions, result *PlaylistResult) error {
+	videos, err := fetchPlaylist(config, client)
+	if err != nil {
+		return err
+	}
+	result.Fetched = len(videos)
+	if options.FetchOnlyDir != "" {
+		result.explain("fetch-only, so saved the raw responses to %s and stopped", options.FetchOnlyDir)
+		log.Printf("Saved the raw responses of playlist %s to %s", config.PlaylistId, options.FetchOnlyDir)
+		return nil
+	}
+
+	videos, shorts := config.splitShorts(config.trackedOnly(videos))
+	if config.Shorts == "separate" && !options.DetectOnly {
+		config.writeFile(newPlaylist(shorts), config.ShortsFileName)
+	}
+
+	// The exports get the fetched videos, the stored files and the diff
+	// only StoredFields.
+	fetched := config.deduplicated(videos)
+	playlist := newPlaylist(config.StoredFields.projected(slices.Clone(fetched)))
+	playlist.FetchedCount = result.Fetched
+	playlist.Note = options.Note
+	playlist.Credential = client.credential()
+	if config.UpdatedAtSource == "latestAdded" {
+		playlist.UpdatedAt = latestAdded(playlist.Playlist, playlist.UpdatedAt)
+	}
+	if options.ReplayDir == "" && config.SearchQuery == "" {
+		config.checkItemCount(client, playlist)
+	}
+	result.explain("fetched %d videos, keeping %d", result.Fetched, len(playlist.Playlist))
+	oldPlaylist, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	oldDiff, _ := readPlaylistFromFile(*config, config.DiffFileName)
+
+	firstRun := errors.Is(err, fs.ErrNotExist)
+	if errors.Is(err, ErrCorruptFile) && !options.DetectOnly {
+		if backupErr := config.backupFile(config.PlaylistFileName); backupErr != nil {
+			return fmt.Errorf("error backing up corrupt %s: %w", config.PlaylistFileName, backupErr)
+		}
+		if !options.Force {
+			return fmt.Errorf("refusing to overwrite %s, a copy is kept as %s.bak; rerun with -force to start a new playlist: %w",
+				config.PlaylistFileName, config.PlaylistFileName, err)
+		}
+		log.Printf("WARNING: replacing corrupt %s, a copy is kept as %s.bak", config.PlaylistFileName, config.PlaylistFileName)
+		firstRun = true
+	}
+	if err != nil && !firstRun {
+		return fmt.Errorf("error reading %s: %w", config.PlaylistFileName, err)
+	}
+
+	if firstRun && options.DetectOnly {
+		result.setChanges(compare(YoutubePlaylist{}, *playlist, config.diffOptions()))
+		return nil
+	}
+	if firstRun {
+		result.explain("no stored %s, so writing it as a new playlist without a diff", config.PlaylistFileName)
+		log.Printf("Error fetching playlist %s a new playlist will be created", err)
+		config.writeFile(playlist, config.PlaylistFileName)
+		changes := compare(YoutubePlaylist{}, *playlist, config.diffOptions())
+		config.writeOutputs(config.StoredFields.unprojected(playlist, changes, fetched))
+		config.writeRemoved(changes)
+		config.appendCounts(playlist, changes)
+		result.setChanges(changes)
+		return nil
+	}
+
+	if !options.Force && config.shrunkTooMuch(len(oldPlaylist.Playlist), len(playlist.Playlist)) {
+		return fmt.Errorf("playlist shrank from %d to %d videos, more than the %d%% ShrinkGuard allows; refusing to overwrite %s, rerun with -force if this is expected",
+			len(oldPlaylist.Playlist), len(playlist.Playlist), config.ShrinkGuard, config.PlaylistFileName)
+	}
+
+	result.explain("stored playlist has %d videos, the new one %d", len(oldPlaylist.Playlist), len(playlist.Playlist))
+	if config.ShrinkGuard > 0 {
+		result.explain("ShrinkGuard of %d%% passed", config.ShrinkGuard)
+	}
+
+	baseline := oldPlaylist
+	if config.BaselineFile != "" {
+		result.explain("diffing against baseline %s instead of the stored playlist", config.BaselineFile)
+	}
+	if config.BaselineFile == "-" {
+		baseline, err = readPlaylist(*config, os.Stdin)
+		if err != nil {
+			return fmt.Errorf("error reading baseline from stdin: %w", err)
+		}
+	} else if config.BaselineFile != "" {
+		baseline, err = readPlaylistFromFile(*config, config.BaselineFile)
+		if err != nil {
+			return fmt.Errorf("error reading baseline %s: %w", config.BaselineFile, err)
+		}
+	}
+
+	changes := compare(baseline, *playlist, config.diffOptions())
+	if changes.PlaylistReplaced {
+		log.Printf("WARNING: none of the %d stored videos of playlist %s are left, it looks replaced", len(baseline.Playlist), config.PlaylistId)
+		if config.ReplacementGuard && !options.Force {
+			return fmt.Errorf("playlist %s looks replaced; refusing to overwrite %s, rerun with -force if this is expected", config.PlaylistId, config.PlaylistFileName)
+		}
+	}
+	diff := playlist.subtract(baseline, config.diffOptions())
+	diff.PlaylistReplaced = changes.PlaylistReplaced
+	diff.UpdatedAt = playlist.UpdatedAt
+	if config.RecoverDeletedTitles {
+		resolver, err := newTitleResolver(config)
+		if err != nil {
+			return fmt.Errorf("error recovering deleted titles: %w", err)
+		}
+		resolver.recoverTitles(diff.Playlist)
+	}
+	result.explain("%d added, %d removed, %d renamed, %d moved", len(changes.Added), len(changes.Removed), len(changes.Renamed), len(changes.Moved))
+	if !config.LegacyDiffFormat {
+		diff.categorize(changes, playlist.Playlist, config.DeletedTitles)
+	}
+	// allReported is set when the stored diff already reported everything in
+	// this one, as against a fixed BaselineFile. The stored diff then stays,
+	// so the next run leaves the same changes out again.
+	allReported := false
+	if config.IncrementalDiff {
+		allReported = !diff.empty()
+		diff = diff.excluding(oldDiff)
+		allReported = allReported && diff.empty()
+		result.DiffUnchanged = allReported
+		result.explain("IncrementalDiff on, so leaving out what %s already reported", config.DiffFileName)
+	}
+	// Legacy diffs report what left the playlist, so a run where videos were
+	// only added, renamed or moved updates the playlist alone, unless
+	// DiffNewVideos writes the new ones as the diff. Categorized diffs report
+	// those changes too.
+	newVideos := playlist.newSince(oldPlaylist)
+	if options.DetectOnly {
+		result.Changed = !diff.empty() || len(newVideos) > 0
+		result.setChanges(changes)
+		return nil
+	}
+	config.writeRemoved(changes)
+	config.appendCounts(playlist, changes)
+
+	if diff.empty() {
+		if len(newVideos) > 0 || !changes.empty() {
+			result.explain("diff empty but %d new videos and %d renamed or moved, so updating %s",
+				len(newVideos), len(changes.Renamed)+len(changes.Moved), config.PlaylistFileName)
+			if config.KeepHistory {
+				if allReported {
+					config.saveHistory(YoutubePlaylist{}, oldPlaylist)
+				} else {
+					config.saveHistory(oldDiff, oldPlaylist)
+				}
+				result.HistorySaved = true
+				result.explain("KeepHistory on, so archived the old snapshot")
+			}
+			config.writeFile(playlist, config.PlaylistFileName)
+			config.writeOutputs(config.StoredFields.unprojected(playlist, changes, fetched))
+			if config.DiffNewVideos && len(newVideos) > 0 {
+				result.explain("DiffNewVideos on, so writing the new videos to %s", config.DiffFileName)
+				config.clearDiff(oldDiff)
+				newDiff := newPlaylist(newVideos)
+				if !config.LegacyDiffFormat {
+					newDiff.Playlist = nil
+					newDiff.categorize(&Changes{Added: newVideos}, nil, nil)
+				}
+				newDiff.Note = options.Note
+				config.writeFile(newDiff, config.DiffFileName)
+			} else if allReported {
+				result.explain("IncrementalDiff left nothing new, so keeping %s", config.DiffFileName)
+			} else {
+				config.writeEmptyDiff(oldDiff)
+			}
+			if len(newVideos) > 0 {
+				log.Printf("Only new videos were found, %d added to the playlist since %s", len(newVideos), oldPlaylist.UpdatedAt.Format(time.RFC3339))
+			} else {
+				log.Println("Only renames or moves were found, updating the playlist")
+			}
+			result.setChanges(changes)
+			return nil
+		} else {
+			if playlist.fillsTitles(oldPlaylist) {
+				result.explain("diff empty but titles missing from %s were fetched, so updating it", config.PlaylistFileName)
+				config.writeFile(playlist, config.PlaylistFileName)
+			} else {
+				result.explain("diff empty and no new videos, so leaving %s as it is", config.PlaylistFileName)
+			}
+			if allReported {
+				result.explain("IncrementalDiff left nothing new, so keeping %s", config.DiffFileName)
+			} else {
+				config.writeEmptyDiff(oldDiff)
+			}
+			log.Println("No diff and no new videos, nothing to do")
+			result.setChanges(changes)
+			return nil
+		}
+	}
+
+	// A diff reporting the same changes as the stored one, as against a
+	// fixed BaselineFile, is left alone rather than rewritten and notified
+	// again.
+	diff.Note = options.Note
+	result.DiffUnchanged = config.sameDiff(*diff, oldDiff)
+	if result.DiffUnchanged {
+		result.explain("diff is the same as the stored %s, so only writing %s", config.DiffFileName, config.PlaylistFileName)
+		log.Printf("Diff unchanged since %s, keeping %s", oldDiff.UpdatedAt.Format(time.RFC3339), config.DiffFileName)
+	} else {
+		result.explain("diff has %d videos, so writing %s and %s", diff.size(), config.PlaylistFileName, config.DiffFileName)
+	}
+	if config.KeepHistory {
+		if result.DiffUnchanged {
+			config.saveHistory(YoutubePlaylist{}, oldPlaylist)
+		} else {
+			config.saveHistory(oldDiff, oldPlaylist)
+		}
+		result.HistorySaved = true
+		result.explain("KeepHistory on, so archived the old snapshot")
+	}
+
+	config.writeFile(playlist, config.PlaylistFileName)
+	if !result.DiffUnchanged {
+		config.writeFile(diff, config.DiffFileName)
+	}
+	config.writeOutputs(config.StoredFields.unprojected(playlist, changes, fetched))
+	result.setChanges(changes)
+	return nil
+}
+
+// latestAdded returns the latest AddedToPlaylistAt of videos, or fallback for
+// an empty playlist.
+func latestAdded(videos []Video, fallback time.Time) time.Time {
+	if len(videos) == 0 {
+		return fallback
+	}
+	var latest time.Time
+	for _, video := range videos {
+		if video.AddedToPlaylistAt.After(latest) {
+			latest = video.AddedToPlaylistAt
+		}
+	}
+	return latest
+}
+
+// itemCountTolerance is the fraction of the reported playlist size that may be
+// missing from pagination before checkItemCount warns.
+const itemCountTolerance = 0.05
+
+// checkItemCount records the playlist size and cover images the API reports
+// and warns when pagination returned noticeably fewer or more items, which
+// means the fetch may be incomplete.
+func (config Config) checkItemCount(client *apiClient, playlist *YoutubePlaylist) {
+	itemCount, thumbnails, err := fetchPlaylistInfo(client, config.PlaylistId)
+	if err != nil {
+		log.Printf("WARNING: error fetching the playlist size, not checking the fetch is complete: %v", err)
+		return
+	}
+	playlist.ItemCount = itemCount
+	if config.TrackThumbnails {
+		playlist.Thumbnails = thumbnails
+	}
+
+	if config.maxVideos > 0 && playlist.FetchedCount == config.maxVideos {
+		return
+	}
+	missing := itemCount - playlist.FetchedCount
+	if float64(abs(missing)) > itemCountTolerance*float64(itemCount) {
+		log.Printf("WARNING: the API reports %d videos in playlist %s but %d were fetched, the playlist may be incomplete",
+			itemCount, config.PlaylistId, playlist.FetchedCount)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func fetchPlaylist(config *Config, client *apiClient) ([]Video, error) {
+	if config.SearchQuery != "" {
+		return fetchSearch(config, client)
+	}
+	videos := newVideoAccumulator()
+	enricher := newVideoEnricher(config, client, videos)
+	sizer := &pageSizer{adaptive: config.AdaptivePageSize}
+	pageToken := ""
+	known := config.knownVideos()
+	emptyRetries := 0
+
+	for {
+		response, err := fetchPlaylistItems(client, config.PlaylistId, pageToken, sizer.size())
+		if err != nil {
+			if sizer.failed(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error fetching playlist items: %w", err)
+		}
+		sizer.succeeded()
+		if len(response.Items) == 0 && response.NextPageToken == "" && emptyRetries < config.EmptyPageRetries &&
+			config.truncated(client, videos.len()) {
+			emptyRetries++
+			delay := client.retry.delay(emptyRetries - 1)
+			log.Printf("WARNING: empty page after %d videos of playlist %s, which the API reports as larger; retrying in %s", videos.len(), config.PlaylistId, delay)
+			select {
+			case <-time.After(delay):
+			case <-client.ctx.Done():
+				return nil, fmt.Errorf("error fetching playlist items: %w", client.ctx.Err())
+			}
+			continue
+		}
+
+		var videoIds []string
+		onlyKnown := len(response.Items) > 0
+		for _, item := range response.Items {
+			if config.maxVideos > 0 && videos.len() == config.maxVideos {
+				break
+			}
+			onlyKnown = onlyKnown && known[item.Snippet.ResourceId.VideoId]
+			if item.Snippet.ResourceId.VideoId == "" {
+				log.Printf("Skipping playlist item %q without a videoId", item.Snippet.Title)
+				continue
+			}
+			converted, err := newVideo(&item)
+			if err != nil {
+				switch config.MissingTimeBehavior {
+				case "error":
+					return nil, fmt.Errorf("playlist item %s: %w", item.Snippet.ResourceId.VideoId, err)
+				case "skip":
+					log.Printf("WARNING: skipping playlist item %s: %v", item.Snippet.ResourceId.VideoId, err)
+					continue
+				default:
+					log.Printf("WARNING: tracking playlist item %s with a zero time: %v", item.Snippet.ResourceId.VideoId, err)
+				}
+			}
+			video := *converted
+			if !config.TrackDescriptions {
+				video.Description = ""
+			}
+			videos.add(video)
+			videoIds = append(videoIds, video.VideoId)
+		}
+		if config.FetchMetadata {
+			enricher.enrich(videoIds)
+		}
+
+		if config.maxVideos > 0 && videos.len() == config.maxVideos && response.NextPageToken != "" {
+			log.Printf("Reached maxVideos, tracking only the first %d videos of playlist %s", config.maxVideos, config.PlaylistId)
+			break
+		}
+		if response.NextPageToken == "" {
+			break
+		}
+		if onlyKnown {
+			log.Printf("Page of playlist %s only has known videos, taking the rest from %s", config.PlaylistId, config.PlaylistFileName)
+			config.addStoredRest(videos)
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	if err := enricher.wait(); err != nil {
+		log.Printf("WARNING: error fetching video metadata, continuing without it: %v", err)
+	}
+
+	return videos.videos(), nil
+}
+
+// truncated reports whether the API reports more items in the playlist than
+// the fetched ones, which it fails to tell in replay mode.
+func (config Config) truncated(client *apiClient, fetched int) bool {
+	if client.replayDir != "" {
+		return false
+	}
+	itemCount, _, err := fetchPlaylistInfo(client, config.PlaylistId)
+	return err == nil && itemCount > fetched
+}
+
+// knownVideos returns the VideoIds of the stored playlist with
+// EarlyStopOnKnown, and nil otherwise or when there is none yet.
+func (config Config) knownVideos() map[string]bool {
+	if !config.EarlyStopOnKnown {
+		return nil
+	}
+	stored, err := readPlaylistFromFile(config, config.PlaylistFileName)
+	if err != nil {
+		return nil
+	}
+	known := make(map[string]bool, len(stored.Playlist))
+	for _, video := range stored.Playlist {
+		known[video.VideoId] = true
+	}
+	return known
+}
+
+// addStoredRest completes a pagination stopped early with the stored videos
+// that were not fetched, after the fetched ones and in their stored order.
+func (config Config) addStoredRest(videos *videoAccumulator) {
+	stored, err := readPlaylistFromFile(config, config.PlaylistFileName)
+	if err != nil {
+		return
+	}
+	fetched := make(map[string]bool)
+	position := 0
+	for _, video := range videos.videos() {
+		fetched[video.VideoId] = true
+		position = max(position, video.Position+1)
+	}
+	for _, video := range inPlaylistOrder(stored.Playlist) {
+		if config.maxVideos > 0 && videos.len() == config.maxVideos {
+			return
+		}
+		if fetched[video.VideoId] {
+			continue
+		}
+		video.Position = position
+		position++
+		videos.add(video)
+	}
+}
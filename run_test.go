@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestJSON writes v as JSON to name in dir.
+func writeTestJSON(t testing.TB, dir, name string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayPage is the playlistItems response listing videos, in order.
+func replayPage(videos []Video) PlaylistItemsResponse {
+	var page PlaylistItemsResponse
+	for i, video := range videos {
+		var item PlaylistItem
+		item.Snippet.Title = video.Title
+		item.Snippet.PublishedAt = video.AddedToPlaylistAt.Format(time.RFC3339)
+		item.Snippet.Position = i
+		item.Snippet.ResourceId.VideoId = video.VideoId
+		page.Items = append(page.Items, item)
+	}
+	return page
+}
+
+// testConfig loads a config tracking playlist P1 in a new directory, with
+// the settings in extra.
+func testConfig(t testing.TB, extra map[string]any) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	settings := map[string]any{"apiKey": "key", "playlistId": "P1", "dirPath": dir}
+	for key, value := range extra {
+		settings[key] = value
+	}
+	writeTestJSON(t, dir, "config.json", settings)
+	config, err := loadConfigFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestRunPlaylistHistoryBranches(t *testing.T) {
+	addedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := Video{Title: "A", VideoId: "aaaaaaaaaaa", AddedToPlaylistAt: addedAt}
+	b := Video{Title: "B", VideoId: "bbbbbbbbbbb", AddedToPlaylistAt: addedAt.Add(time.Hour)}
+	c := Video{Title: "C", VideoId: "ccccccccccc", AddedToPlaylistAt: addedAt.Add(2 * time.Hour)}
+
+	tests := []struct {
+		name     string
+		fetched  []Video
+		wantDiff bool
+	}{
+		{name: "no change", fetched: []Video{a, b}},
+		{name: "only new videos", fetched: []Video{a, b, c}},
+		{name: "videos removed", fetched: []Video{a}, wantDiff: true},
+	}
+	for _, test := range tests {
+		for _, keepHistory := range []bool{false, true} {
+			config := testConfig(t, map[string]any{"keepHistory": keepHistory, "legacyDiffFormat": true})
+			stored := newPlaylist([]Video{a, b})
+			stored.UpdatedAt = addedAt.Add(24 * time.Hour)
+			writeTestJSON(t, config.DirPath, config.PlaylistFileName, stored)
+			replayDir := t.TempDir()
+			writeTestJSON(t, replayDir, replayFileName(""), replayPage(test.fetched))
+
+			var archived []YoutubePlaylist
+			saveHistoryHook = func(oldPlaylist YoutubePlaylist) {
+				archived = append(archived, oldPlaylist)
+			}
+			t.Cleanup(func() { saveHistoryHook = nil })
+
+			result, err := Run(config, RunOptions{ReplayDir: replayDir})
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			wantArchived := keepHistory && test.name != "no change"
+			if (len(archived) > 0) != wantArchived {
+				t.Errorf("%s with keepHistory %v: saveHistory called %d times, want called %v", test.name, keepHistory, len(archived), wantArchived)
+			}
+			if result.Playlists[0].HistorySaved != wantArchived {
+				t.Errorf("%s with keepHistory %v: HistorySaved is %v", test.name, keepHistory, result.Playlists[0].HistorySaved)
+			}
+			for _, snapshot := range archived {
+				if len(snapshot.Playlist) != 2 {
+					t.Errorf("%s: archived %d videos, want the 2 stored ones", test.name, len(snapshot.Playlist))
+				}
+			}
+
+			written, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(written.Playlist) != len(test.fetched) {
+				t.Errorf("%s: %s has %d videos, want %d", test.name, config.PlaylistFileName, len(written.Playlist), len(test.fetched))
+			}
+			_, err = os.Stat(filepath.Join(config.DirPath, config.DiffFileName))
+			if hasDiff := !errors.Is(err, fs.ErrNotExist); hasDiff != test.wantDiff {
+				t.Errorf("%s: %s written is %v, want %v", test.name, config.DiffFileName, hasDiff, test.wantDiff)
+			}
+		}
+	}
+}
+
+func TestFetchPlaylistSkipsItemsWithoutVideoId(t *testing.T) {
+	videos := testPlaylist(2).Playlist
+	page := replayPage(videos)
+	var partial PlaylistItem
+	partial.Snippet.Title = "Private video"
+	page.Items = slices.Insert(page.Items, 1, partial, PlaylistItem{})
+
+	config := testConfig(t, nil)
+	replayDir := t.TempDir()
+	writeTestJSON(t, replayDir, replayFileName(""), page)
+	if _, err := Run(config, RunOptions{ReplayDir: replayDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Playlist) != len(videos) {
+		t.Fatalf("%s has %d videos, want the %d with a videoId", config.PlaylistFileName, len(written.Playlist), len(videos))
+	}
+	for i, video := range written.Playlist {
+		if video.VideoId != videos[i].VideoId {
+			t.Errorf("video %d is %q, want %s", i, video.VideoId, videos[i].VideoId)
+		}
+	}
+}
+
+func TestIncrementalDiffAgainstBaselineReportsOnce(t *testing.T) {
+	videos := testPlaylist(4).Playlist
+	config := testConfig(t, map[string]any{"incrementalDiff": true, "baselineFile": "baseline.json"})
+	writeTestJSON(t, config.DirPath, "baseline.json", newPlaylist(videos[:2]))
+	writeTestJSON(t, config.DirPath, config.PlaylistFileName, newPlaylist(videos[:2]))
+
+	fetched := slices.Clone(videos)
+	fetched[1].Title = "Renamed"
+	var diffs []YoutubePlaylist
+	// The second run finds one more video than the first.
+	for _, count := range []int{3, 4} {
+		replayDir := t.TempDir()
+		writeTestJSON(t, replayDir, replayFileName(""), replayPage(fetched[:count]))
+		if _, err := Run(config, RunOptions{ReplayDir: replayDir}); err != nil {
+			t.Fatal(err)
+		}
+		diff, err := readPlaylistFromFile(*config, config.DiffFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs = append(diffs, diff)
+	}
+
+	first, second := diffs[0], diffs[1]
+	if len(first.Added) != 1 || first.Added[0].VideoId != videos[2].VideoId || len(first.Renamed) != 1 || first.Renamed[0].OldTitle != videos[1].Title {
+		t.Errorf("first run reported added %v and renamed %v, want the third video and the rename", first.Added, first.Renamed)
+	}
+	if len(second.Added) != 1 || second.Added[0].VideoId != videos[3].VideoId || len(second.Renamed) != 0 {
+		t.Errorf("second run reported added %v and renamed %v, want only the fourth video", second.Added, second.Renamed)
+	}
+}
+
+func TestIncrementalDiffKeepsDiffWhenAllReported(t *testing.T) {
+	videos := testPlaylist(3).Playlist
+	config := testConfig(t, map[string]any{"incrementalDiff": true, "baselineFile": "baseline.json"})
+	writeTestJSON(t, config.DirPath, "baseline.json", newPlaylist(videos[:2]))
+	writeTestJSON(t, config.DirPath, config.PlaylistFileName, newPlaylist(videos[:2]))
+	replayDir := t.TempDir()
+	writeTestJSON(t, replayDir, replayFileName(""), replayPage(videos))
+
+	var first YoutubePlaylist
+	for run := range 3 {
+		result, err := Run(config, RunOptions{ReplayDir: replayDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := readPlaylistFromFile(*config, config.DiffFileName)
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		if run == 0 {
+			first = diff
+		} else if !diff.UpdatedAt.Equal(first.UpdatedAt) {
+			t.Errorf("run %d rewrote %s, want the first diff kept", run+1, config.DiffFileName)
+		}
+		if unchanged := result.Playlists[0].DiffUnchanged; unchanged != (run > 0) {
+			t.Errorf("run %d: DiffUnchanged is %v", run+1, unchanged)
+		}
+	}
+}
+
+func TestExportsKeepFieldsStoredFieldsLeavesOut(t *testing.T) {
+	videos := testPlaylist(2).Playlist
+	config := testConfig(t, map[string]any{"storedFields": []string{"position"}, "strmDir": "strm", "outputFormats": []string{"json", "changelog"}})
+	replayDir := t.TempDir()
+	writeTestJSON(t, replayDir, replayFileName(""), replayPage(videos))
+	if _, err := Run(config, RunOptions{ReplayDir: replayDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Playlist) != 2 || written.Playlist[0].Title != "" {
+		t.Errorf("%s holds %v, want the 2 videos without titles", config.PlaylistFileName, written.Playlist)
+	}
+	for _, video := range videos {
+		if _, err := os.Stat(filepath.Join(config.DirPath, "strm", strmFileName(video))); err != nil {
+			t.Errorf("no .strm file named after %q: %v", video.Title, err)
+		}
+	}
+	format, _ := findOutputFormat("changelog")
+	changelog, err := os.ReadFile(filepath.Join(config.DirPath, config.outputFileName(format)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(changelog), videos[1].Title) {
+		t.Errorf("changelog leaves out the titles:\n%s", changelog)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// listResponseFields are the keys every list response has besides its items.
+var listResponseFields = []string{"kind", "etag", "nextPageToken", "prevPageToken", "pageInfo"}
+
+// ignoredAPIFields are the paths of the fields each endpoint returns for the
+// parts requested that are deliberately not modeled, so WarnAPISchemaDrift
+// only reports new ones.
+var ignoredAPIFields = map[string][]string{
+	"playlistItems": slices.Concat(listResponseFields, []string{
+		"items[].kind", "items[].etag", "items[].id",
+		"items[].snippet.channelId", "items[].snippet.channelTitle", "items[].snippet.playlistId",
+		"items[].snippet.thumbnails", "items[].snippet.resourceId.kind",
+		"items[].contentDetails.videoId", "items[].contentDetails.note",
+		"items[].contentDetails.startAt", "items[].contentDetails.endAt",
+	}),
+	"playlists": slices.Concat(listResponseFields, []string{
+		"items[].kind", "items[].etag", "items[].id",
+		"items[].snippet.publishedAt", "items[].snippet.channelId", "items[].snippet.title",
+		"items[].snippet.description", "items[].snippet.channelTitle", "items[].snippet.defaultLanguage",
+		"items[].snippet.localized", "items[].snippet.thumbnails.*.width", "items[].snippet.thumbnails.*.height",
+	}),
+	// videos is requested with status and contentDetails for the metadata
+	// and with snippet for imports.
+	"videos": slices.Concat(listResponseFields, []string{
+		"items[].kind", "items[].etag",
+		"items[].snippet.description", "items[].snippet.thumbnails", "items[].snippet.tags",
+		"items[].snippet.categoryId", "items[].snippet.liveBroadcastContent", "items[].snippet.defaultLanguage",
+		"items[].snippet.localized", "items[].snippet.defaultAudioLanguage",
+		"items[].status.uploadStatus", "items[].status.failureReason", "items[].status.rejectionReason",
+		"items[].status.publishAt", "items[].status.license", "items[].status.embeddable",
+		"items[].status.publicStatsViewable", "items[].status.madeForKids", "items[].status.selfDeclaredMadeForKids",
+		"items[].status.containsSyntheticMedia",
+		"items[].contentDetails.dimension", "items[].contentDetails.definition", "items[].contentDetails.caption",
+		"items[].contentDetails.licensedContent", "items[].contentDetails.contentRating",
+		"items[].contentDetails.projection", "items[].contentDetails.hasCustomThumbnail",
+	}),
+	"search": slices.Concat(listResponseFields, []string{
+		"regionCode", "items[].kind", "items[].etag", "items[].id.kind",
+		"items[].snippet.thumbnails", "items[].snippet.liveBroadcastContent", "items[].snippet.publishTime",
+	}),
+}
+
+// expectedAPIFields are the fields of each endpoint's items the tracking
+// relies on, which decode to silent zero values when the API drops them.
+var expectedAPIFields = map[string][]string{
+	"playlistItems": {"snippet.title", "snippet.publishedAt", "snippet.resourceId.videoId"},
+	"playlists":     {"contentDetails.itemCount"},
+}
+
+// checkSchema warns, once per field and run, about fields of an endpoint's
+// response that v does not model and about missing expected fields.
+func (client *apiClient) checkSchema(endpoint string, data []byte, v any) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	for _, path := range unknownFields(raw, reflect.TypeOf(v), "", ignoredAPIFields[endpoint]) {
+		client.warnOnce("WARNING: the %s response has a field %s that is not tracked, the API may have changed", endpoint, path)
+	}
+
+	response, _ := raw.(map[string]any)
+	items, _ := response["items"].([]any)
+	for _, item := range items {
+		for _, path := range expectedAPIFields[endpoint] {
+			if !hasField(item, path) {
+				client.warnOnce("WARNING: %s items are missing the expected field %s, the API may have changed", endpoint, path)
+			}
+		}
+	}
+}
+
+func (client *apiClient) warnOnce(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	client.schemaMu.Lock()
+	defer client.schemaMu.Unlock()
+	if client.schemaWarnings == nil {
+		client.schemaWarnings = make(map[string]bool)
+	}
+	if !client.schemaWarnings[message] {
+		client.schemaWarnings[message] = true
+		log.Print(message)
+	}
+}
+
+// unknownFields lists the paths of the keys of raw that have no json field in
+// t, the type it is decoded into, and are not in ignored.
+func unknownFields(raw any, t reflect.Type, path string, ignored []string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var unknown []string
+	switch value := raw.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			for key, child := range value {
+				field, found := jsonField(t, key)
+				if !found {
+					if !slices.Contains(ignored, joinPath(path, key)) {
+						unknown = append(unknown, joinPath(path, key))
+					}
+					continue
+				}
+				unknown = append(unknown, unknownFields(child, field.Type, joinPath(path, key), ignored)...)
+			}
+		case reflect.Map:
+			for _, child := range value {
+				unknown = append(unknown, unknownFields(child, t.Elem(), joinPath(path, "*"), ignored)...)
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice {
+			for _, child := range value {
+				unknown = append(unknown, unknownFields(child, t.Elem(), path+"[]", ignored)...)
+			}
+		}
+	}
+	slices.Sort(unknown)
+	return slices.Compact(unknown)
+}
+
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// hasField reports whether the dotted path is present in raw.
+func hasField(raw any, path string) bool {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return false
+		}
+		if raw, ok = object[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// searchPageSize is the most results the search endpoint returns per page.
+const searchPageSize = 50
+
+type SearchResponse struct {
+	Items []struct {
+		Id struct {
+			VideoId string `json:"videoId"`
+		} `json:"id"`
+		Snippet struct {
+			Title        string `json:"title"`
+			PublishedAt  string `json:"publishedAt"`
+			Description  string `json:"description"`
+			ChannelId    string `json:"channelId"`
+			ChannelTitle string `json:"channelTitle"`
+		} `json:"snippet"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func fetchSearchPage(client *apiClient, query, pageToken string, maxResults int) (*SearchResponse, error) {
+	params := url.Values{}
+	params.Set("part", "snippet")
+	params.Set("type", "video")
+	params.Set("q", query)
+	params.Set("maxResults", strconv.Itoa(maxResults))
+	if pageToken != "" {
+		params.Set("pageToken", pageToken)
+	}
+
+	var response SearchResponse
+	if err := client.getJSON("search", params, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// sourceName names what a single-playlist config tracks, for the summary.
+func (config Config) sourceName() string {
+	if config.SearchQuery != "" {
+		return fmt.Sprintf("search %q", config.SearchQuery)
+	}
+	return config.PlaylistId
+}
+
+// fetchSearch collects the top SearchMaxResults videos for SearchQuery, in
+// the order the API ranks them, as a pseudo-playlist. Position is the rank
+// and both dates are when the video was published.
+func fetchSearch(config *Config, client *apiClient) ([]Video, error) {
+	videos := newVideoAccumulator()
+	enricher := newVideoEnricher(config, client, videos)
+	pageToken := ""
+
+	for {
+		remaining := config.SearchMaxResults - videos.len()
+		response, err := fetchSearchPage(client, config.SearchQuery, pageToken, min(remaining, searchPageSize))
+		if err != nil {
+			return nil, fmt.Errorf("error fetching search results: %w", err)
+		}
+
+		var videoIds []string
+		for _, item := range response.Items {
+			if videos.len() == config.SearchMaxResults {
+				break
+			}
+			if item.Id.VideoId == "" {
+				continue
+			}
+			publishedAt, err := time.Parse(time.RFC3339, item.Snippet.PublishedAt)
+			if err != nil {
+				log.Printf("WARNING: search result %s has an invalid publishedAt %q", item.Id.VideoId, item.Snippet.PublishedAt)
+			}
+			video := Video{Title: item.Snippet.Title, VideoId: item.Id.VideoId, AddedToPlaylistAt: publishedAt, UploadedAt: publishedAt,
+				Position: videos.len(), ChannelTitle: item.Snippet.ChannelTitle, ChannelId: item.Snippet.ChannelId}
+			if config.TrackDescriptions {
+				video.Description = item.Snippet.Description
+			}
+			videos.add(video)
+			videoIds = append(videoIds, video.VideoId)
+		}
+		if config.FetchMetadata {
+			enricher.enrich(videoIds)
+		}
+
+		if videos.len() == config.SearchMaxResults || response.NextPageToken == "" {
+			break
+		}
+		pageToken = response.NextPageToken
+	}
+	if err := enricher.wait(); err != nil {
+		log.Printf("WARNING: error fetching video metadata, continuing without it: %v", err)
+	}
+
+	return videos.videos(), nil
+}
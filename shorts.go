@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Videos up to this long are classified as Shorts.
+const shortMaxDuration = 60 * time.Second
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseISODuration parses the ISO 8601 durations the API uses for
+// contentDetails.duration, such as "PT1M5S" or "P1DT2H".
+func parseISODuration(s string) (time.Duration, error) {
+	match := isoDurationPattern.FindStringSubmatch(s)
+	if match == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+
+	var duration time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(n) * unit
+	}
+	return duration, nil
+}
+
+// splitShorts separates Shorts from the other videos according to the
+// Shorts setting. With "include" nothing is split off.
+func (config Config) splitShorts(videos []Video) (kept []Video, shorts []Video) {
+	if config.Shorts == "" || config.Shorts == "include" {
+		return videos, nil
+	}
+	for _, video := range videos {
+		if video.IsShort {
+			shorts = append(shorts, video)
+		} else {
+			kept = append(kept, video)
+		}
+	}
+	return kept, shorts
+}
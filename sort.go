@@ -0,0 +1,41 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+var videoOrderings = map[string]func(a, b Video) int{
+	"title": func(a, b Video) int {
+		return strings.Compare(a.Title, b.Title)
+	},
+	"publishedAt": func(a, b Video) int {
+		return a.AddedToPlaylistAt.Compare(b.AddedToPlaylistAt)
+	},
+	"uploadedAt": func(a, b Video) int {
+		return a.UploadedAt.Compare(b.UploadedAt)
+	},
+	"position": func(a, b Video) int {
+		return cmp.Compare(a.Position, b.Position)
+	},
+}
+
+// sortedPlaylist returns a copy of playlist ordered by sortBy, leaving the
+// original untouched. Unknown or empty orderings return playlist as is.
+func sortedPlaylist(playlist *YoutubePlaylist, sortBy string) *YoutubePlaylist {
+	compare, found := videoOrderings[sortBy]
+	if !found {
+		return playlist
+	}
+
+	sorted := *playlist
+	sorted.Playlist = slices.Clone(playlist.Playlist)
+	slices.SortStableFunc(sorted.Playlist, func(a, b Video) int {
+		if c := compare(a, b); c != 0 {
+			return c
+		}
+		return strings.Compare(a.VideoId, b.VideoId)
+	})
+	return &sorted
+}
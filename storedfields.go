@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// videoFields are the JSON keys of Video in declaration order, the order
+// fields are stored in.
+var videoFields = func() []string {
+	var fields []string
+	videoType := reflect.TypeFor[Video]()
+	for i := range videoType.NumField() {
+		name, _, _ := strings.Cut(videoType.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	return fields
+}()
+
+func validateStoredFields(fields []string) error {
+	for _, field := range fields {
+		if !slices.Contains(videoFields, field) {
+			return fmt.Errorf("unknown storedFields entry %q, must be one of %s", field, strings.Join(videoFields, ", "))
+		}
+	}
+	return nil
+}
+
+// storedFields are the video fields StoredFields keeps in the JSON files,
+// every field when empty.
+type storedFields []string
+
+// stores reports whether field is kept. The videoId is always kept, since
+// diffs match videos on it.
+func (fields storedFields) stores(field string) bool {
+	return len(fields) == 0 || field == "videoId" || slices.Contains(fields, field)
+}
+
+// projectVideo encodes video with only the stored fields, in declaration
+// order.
+func (fields storedFields) projectVideo(video Video) (json.RawMessage, error) {
+	data, err := json.Marshal(video)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for _, field := range videoFields {
+		value, found := values[field]
+		if !found || !fields.stores(field) {
+			continue
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// projected clears the fields StoredFields leaves out, so fetched videos
+// compare equal to stored ones on what is stored.
+func (fields storedFields) projected(videos []Video) []Video {
+	if len(fields) == 0 {
+		return videos
+	}
+	for i, video := range videos {
+		data, err := fields.projectVideo(video)
+		if err != nil {
+			continue
+		}
+		var reduced Video
+		if json.Unmarshal(data, &reduced) == nil {
+			videos[i] = reduced
+		}
+	}
+	return videos
+}
+
+// unprojected gives the videos of playlist and changes back the fields left
+// out of them, from the fetched ones, for the exports.
+func (fields storedFields) unprojected(playlist *YoutubePlaylist, changes *Changes, fetched []Video) (*YoutubePlaylist, *Changes) {
+	if len(fields) == 0 {
+		return playlist, changes
+	}
+	byVideoId := make(map[string]Video, len(fetched))
+	for _, video := range fetched {
+		if _, found := byVideoId[video.VideoId]; !found {
+			byVideoId[video.VideoId] = video
+		}
+	}
+	restore := func(video Video) Video {
+		if full, found := byVideoId[video.VideoId]; found {
+			return full
+		}
+		return video
+	}
+
+	exported := *playlist
+	exported.Playlist = slices.Clone(playlist.Playlist)
+	for i, video := range exported.Playlist {
+		// Repeated videos keep their own entry, which a lookup by
+		// VideoId cannot tell apart.
+		if i < len(fetched) && fetched[i].VideoId == video.VideoId {
+			exported.Playlist[i] = fetched[i]
+		} else {
+			exported.Playlist[i] = restore(video)
+		}
+	}
+	exportedChanges := *changes
+	exportedChanges.Added = slices.Clone(changes.Added)
+	for i, video := range exportedChanges.Added {
+		exportedChanges.Added[i] = restore(video)
+	}
+	exportedChanges.Renamed = slices.Clone(changes.Renamed)
+	for i := range exportedChanges.Renamed {
+		exportedChanges.Renamed[i].Video = restore(exportedChanges.Renamed[i].Video)
+	}
+	exportedChanges.Moved = slices.Clone(changes.Moved)
+	for i := range exportedChanges.Moved {
+		exportedChanges.Moved[i].Video = restore(exportedChanges.Moved[i].Video)
+	}
+	return &exported, &exportedChanges
+}
+
+func (fields storedFields) projectVideos(videos []Video) ([]json.RawMessage, error) {
+	projected := make([]json.RawMessage, len(videos))
+	for i, video := range videos {
+		data, err := fields.projectVideo(video)
+		if err != nil {
+			return nil, err
+		}
+		projected[i] = data
+	}
+	return projected, nil
+}
+
+// storedCategories are the change categories of a diff with only the
+// StoredFields of their videos.
+type storedCategories struct {
+	Added   []json.RawMessage `json:"added,omitempty"`
+	Removed []json.RawMessage `json:"removed,omitempty"`
+	Renamed []storedRename    `json:"renamed,omitempty"`
+	Moved   []storedMove      `json:"moved,omitempty"`
+	Deleted []json.RawMessage `json:"deleted,omitempty"`
+
+	AvailabilityChanged []storedAvailabilityChange `json:"availabilityChanged,omitempty"`
+}
+
+type storedRename struct {
+	Video    json.RawMessage `json:"video"`
+	OldTitle string          `json:"oldTitle"`
+}
+
+type storedAvailabilityChange struct {
+	Video           json.RawMessage `json:"video"`
+	OldAvailability string          `json:"oldAvailability"`
+}
+
+type storedMove struct {
+	Video       json.RawMessage `json:"video"`
+	OldPosition int             `json:"oldPosition"`
+	NewPosition int             `json:"newPosition"`
+}
+
+func (fields storedFields) categories(playlist *YoutubePlaylist) (stored storedCategories, err error) {
+	if stored.Added, err = fields.projectVideos(playlist.Added); err != nil {
+		return stored, err
+	}
+	if stored.Removed, err = fields.projectVideos(playlist.Removed); err != nil {
+		return stored, err
+	}
+	if stored.Deleted, err = fields.projectVideos(playlist.Deleted); err != nil {
+		return stored, err
+	}
+	for _, rename := range playlist.Renamed {
+		video, err := fields.projectVideo(rename.Video)
+		if err != nil {
+			return stored, err
+		}
+		stored.Renamed = append(stored.Renamed, storedRename{Video: video, OldTitle: rename.OldTitle})
+	}
+	for _, move := range playlist.Moved {
+		video, err := fields.projectVideo(move.Video)
+		if err != nil {
+			return stored, err
+		}
+		stored.Moved = append(stored.Moved, storedMove{Video: video, OldPosition: move.OldPosition, NewPosition: move.NewPosition})
+	}
+	for _, change := range playlist.AvailabilityChanged {
+		video, err := fields.projectVideo(change.Video)
+		if err != nil {
+			return stored, err
+		}
+		stored.AvailabilityChanged = append(stored.AvailabilityChanged, storedAvailabilityChange{Video: video, OldAvailability: change.OldAvailability})
+	}
+	return stored, nil
+}
+
+// encode encodes playlist with only the stored fields of its videos, for
+// writeJSON. Categorized diffs are written without videos, with their
+// categories last.
+func (fields storedFields) encode(playlist *YoutubePlaylist) (json.RawMessage, error) {
+	// Encode the rest of the playlist as usual and splice the videos in,
+	// which keeps the key order readPlaylistFromFile sees elsewhere.
+	rest := *playlist
+	rest.Playlist = nil
+	rest.Added, rest.Removed, rest.Renamed, rest.Moved, rest.Deleted = nil, nil, nil, nil, nil
+	rest.AvailabilityChanged = nil
+	data, err := json.Marshal(rest)
+	if err != nil {
+		return nil, err
+	}
+	if !playlist.categorized() {
+		videos, err := fields.projectVideos(playlist.Playlist)
+		if err != nil {
+			return nil, err
+		}
+		encodedVideos, err := json.Marshal(videos)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Replace(data, []byte(`"videos":null`), append([]byte(`"videos":`), encodedVideos...), 1), nil
+	}
+
+	data = bytes.Replace(data, []byte(`"videos":null,`), nil, 1)
+	categories, err := fields.categories(playlist)
+	if err != nil {
+		return nil, err
+	}
+	encodedCategories, err := json.Marshal(categories)
+	if err != nil {
+		return nil, err
+	}
+	if len(encodedCategories) > len("{}") {
+		data = append(append(data[:len(data)-1], ','), encodedCategories[1:]...)
+	}
+	return data, nil
+}
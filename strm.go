@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const strmMaxTitleLength = 100
+
+// strmFileName names the .strm file of a video after its title, made safe
+// for file systems, and its VideoId, which keeps names unique.
+func strmFileName(video Video) string {
+	title := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, video.Title)
+	if runes := []rune(title); len(runes) > strmMaxTitleLength {
+		title = string(runes[:strmMaxTitleLength])
+	}
+	title = strings.Trim(title, " .")
+	return fmt.Sprintf("%s [%s].strm", title, video.VideoId)
+}
+
+// writeStrm mirrors the playlist into StrmDir for media servers such as Kodi
+// or Jellyfin: a .strm file holding the watch URL of each available video,
+// and no .strm files for videos that left the playlist.
+func (config Config) writeStrm(playlist *YoutubePlaylist) {
+	if config.StrmDir == "" {
+		return
+	}
+	dir := config.StrmDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(config.DirPath, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("WARNING: error creating strmDir: %v", err)
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, video := range playlist.Playlist {
+		if unavailable(video, config.DeletedTitles) {
+			continue
+		}
+		fileName := strmFileName(video)
+		wanted[fileName] = true
+		err := createFile(filepath.Join(dir, fileName), config.FileMode, func(w io.Writer) error {
+			_, err := io.WriteString(w, watchURL(video.VideoId)+"\n")
+			return err
+		})
+		if err != nil {
+			log.Printf("WARNING: error writing %s: %v", fileName, err)
+		}
+	}
+
+	existing, err := filepath.Glob(filepath.Join(dir, "*.strm"))
+	if err != nil {
+		log.Printf("WARNING: error listing strmDir: %v", err)
+		return
+	}
+	for _, path := range existing {
+		if !wanted[filepath.Base(path)] {
+			os.Remove(path)
+		}
+	}
+	fmt.Println("STRM files written to", dir)
+}
@@ -0,0 +1,86 @@
+package main
+
+import "slices"
+
+// AvailabilityChange is a video still available whose availability changed,
+// such as from private to public or public to blocked.
+type AvailabilityChange struct {
+	Video           Video  `json:"video"`
+	OldAvailability string `json:"oldAvailability"`
+}
+
+// categorize fills the change categories of diff from the flat list of
+// videos subtract left in it, the current videos and the changes of the same
+// run. Videos gone from the playlist are removed. Those still in it are
+// deleted when they are now deleted or private, renamed when a real title
+// replaced a deleted one, and otherwise changed availability.
+func (diff *YoutubePlaylist) categorize(changes *Changes, current []Video, deletedTitles []string) {
+	removed := make(map[string]bool)
+	for _, video := range changes.Removed {
+		removed[video.VideoId] = true
+	}
+	currentVideos := make(map[string]Video)
+	for _, video := range current {
+		currentVideos[video.VideoId] = video
+	}
+
+	covered := make(map[string]bool)
+	diff.Removed, diff.Deleted, diff.Renamed, diff.AvailabilityChanged = []Video{}, []Video{}, []Rename{}, []AvailabilityChange{}
+	for _, video := range diff.Playlist {
+		now, found := currentVideos[video.VideoId]
+		covered[video.VideoId] = true
+		switch {
+		case removed[video.VideoId] || !found:
+			diff.Removed = append(diff.Removed, video)
+		case unavailable(now, deletedTitles):
+			diff.Deleted = append(diff.Deleted, video)
+		case now.Title != video.Title:
+			diff.Renamed = append(diff.Renamed, Rename{Video: now, OldTitle: video.Title})
+		default:
+			diff.AvailabilityChanged = append(diff.AvailabilityChanged, AvailabilityChange{Video: now, OldAvailability: video.Availability})
+		}
+	}
+
+	diff.Added = slices.Clone(changes.Added)
+	for _, rename := range changes.Renamed {
+		if !covered[rename.Video.VideoId] {
+			diff.Renamed = append(diff.Renamed, rename)
+		}
+	}
+	diff.Moved = slices.Clone(changes.Moved)
+	if diff.Added == nil {
+		diff.Added = []Video{}
+	}
+	if diff.Moved == nil {
+		diff.Moved = []Move{}
+	}
+}
+
+// categorized reports whether p is a diff written with change categories
+// rather than as a flat list of videos.
+func (p YoutubePlaylist) categorized() bool {
+	return p.Added != nil || p.Removed != nil || p.Renamed != nil || p.Moved != nil || p.Deleted != nil ||
+		p.AvailabilityChanged != nil
+}
+
+// size counts the changed videos of a diff, across every category of
+// categorized ones.
+func (p YoutubePlaylist) size() int {
+	if !p.categorized() {
+		return len(p.Playlist)
+	}
+	return len(p.Added) + len(p.Removed) + len(p.Renamed) + len(p.Moved) + len(p.Deleted) + len(p.AvailabilityChanged)
+}
+
+// flatten rebuilds the flat list of videos of a categorized diff read back, so
+// it compares with the diffs of later runs like one written as a list.
+func (p *YoutubePlaylist) flatten() {
+	if len(p.Playlist) == 0 && p.categorized() {
+		p.Playlist = append(slices.Clone(p.Removed), p.Deleted...)
+		for _, change := range p.AvailabilityChanged {
+			video := change.Video
+			video.Availability = change.OldAvailability
+			p.Playlist = append(p.Playlist, video)
+		}
+	}
+}
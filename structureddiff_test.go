@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestCategorizeTransitions(t *testing.T) {
+	video := func(videoId, title, availability string) Video {
+		return Video{Title: title, VideoId: videoId, Availability: availability}
+	}
+	tests := []struct {
+		name        string
+		old, now    Video
+		wantDeleted bool
+		wantRenamed string
+		wantChanged string
+	}{
+		{name: "public to private", old: video("aaaaaaaaaaa", "A", "public"), now: video("aaaaaaaaaaa", "A", "private"), wantDeleted: true},
+		{name: "private to public", old: video("aaaaaaaaaaa", "A", "private"), now: video("aaaaaaaaaaa", "A", "public"), wantChanged: "private"},
+		{name: "public to blocked", old: video("aaaaaaaaaaa", "A", "public"), now: video("aaaaaaaaaaa", "A", "blocked"), wantChanged: "public"},
+		{name: "blocked to public", old: video("aaaaaaaaaaa", "A", "blocked"), now: video("aaaaaaaaaaa", "A", "public"), wantChanged: "blocked"},
+		{name: "deleted", old: video("aaaaaaaaaaa", "A", ""), now: video("aaaaaaaaaaa", "Deleted video", ""), wantDeleted: true},
+		{name: "restored", old: video("aaaaaaaaaaa", "Deleted video", ""), now: video("aaaaaaaaaaa", "B restored", ""), wantRenamed: "Deleted video"},
+		{name: "private title to public", old: video("aaaaaaaaaaa", "Private video", "private"), now: video("aaaaaaaaaaa", "C", "public"), wantRenamed: "Private video"},
+	}
+	for _, test := range tests {
+		old, current := newPlaylist([]Video{test.old}), newPlaylist([]Video{test.now})
+		options := diffOptions{deletedTitles: defaultDeletedTitles}
+		diff := current.subtract(*old, options)
+		diff.categorize(compare(*old, *current, options), current.Playlist, defaultDeletedTitles)
+
+		if len(diff.Removed) != 0 || len(diff.Added) != 0 {
+			t.Errorf("%s: reported %d removed and %d added, want none", test.name, len(diff.Removed), len(diff.Added))
+		}
+		if deleted := len(diff.Deleted) == 1; deleted != test.wantDeleted || len(diff.Deleted) > 1 {
+			t.Errorf("%s: deleted %v, want %v", test.name, diff.Deleted, test.wantDeleted)
+		}
+		switch {
+		case test.wantRenamed == "" && len(diff.Renamed) != 0:
+			t.Errorf("%s: renamed %v, want none", test.name, diff.Renamed)
+		case test.wantRenamed != "" && (len(diff.Renamed) != 1 || diff.Renamed[0].OldTitle != test.wantRenamed || diff.Renamed[0].Video.Title != test.now.Title):
+			t.Errorf("%s: renamed %v, want one from %q to %q", test.name, diff.Renamed, test.wantRenamed, test.now.Title)
+		}
+		switch {
+		case test.wantChanged == "" && len(diff.AvailabilityChanged) != 0:
+			t.Errorf("%s: availability changed %v, want none", test.name, diff.AvailabilityChanged)
+		case test.wantChanged != "" && (len(diff.AvailabilityChanged) != 1 || diff.AvailabilityChanged[0].OldAvailability != test.wantChanged ||
+			diff.AvailabilityChanged[0].Video.Availability != test.now.Availability):
+			t.Errorf("%s: availability changed %v, want one from %s to %s", test.name, diff.AvailabilityChanged, test.wantChanged, test.now.Availability)
+		}
+	}
+}
+
+func TestCategorizeRemoved(t *testing.T) {
+	old := newPlaylist([]Video{{Title: "A", VideoId: "aaaaaaaaaaa"}, {Title: "B", VideoId: "bbbbbbbbbbb"}})
+	current := newPlaylist([]Video{{Title: "B", VideoId: "bbbbbbbbbbb"}})
+	options := diffOptions{deletedTitles: defaultDeletedTitles}
+	diff := current.subtract(*old, options)
+	diff.categorize(compare(*old, *current, options), current.Playlist, defaultDeletedTitles)
+
+	if len(diff.Removed) != 1 || diff.Removed[0].VideoId != "aaaaaaaaaaa" || len(diff.Deleted) != 0 {
+		t.Errorf("removed %v and deleted %v, want only A removed", diff.Removed, diff.Deleted)
+	}
+}
+
+func TestSizeCountsEveryCategory(t *testing.T) {
+	videos := testPlaylist(3).Playlist
+	diff := newPlaylist(nil)
+	diff.categorize(&Changes{Added: videos[:2], Renamed: []Rename{{Video: videos[2], OldTitle: "Old"}}}, videos, defaultDeletedTitles)
+	if size := diff.size(); size != 3 {
+		t.Errorf("size of a diff with 2 added and 1 renamed is %d, want 3", size)
+	}
+	if size := newPlaylist(videos).size(); size != 3 {
+		t.Errorf("size of a legacy diff of 3 videos is %d", size)
+	}
+}
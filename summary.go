@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// useColor reports whether the summary should be colorized: only on a
+// terminal, and never with -no-color or NO_COLOR set (https://no-color.org).
+func useColor(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printSummary writes the end-of-run summary for one playlist in a single
+// write, so summaries of playlists processed concurrently do not interleave.
+func printSummary(w io.Writer, name string, changes *Changes, color bool) {
+	paint := func(code, line string) string {
+		if !color {
+			return line
+		}
+		return code + line + ansiReset
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d added, %d removed, %d renamed, %d moved\n",
+		name, len(changes.Added), len(changes.Removed), len(changes.Renamed), len(changes.Moved))
+	if changes.PlaylistReplaced {
+		fmt.Fprintln(&b, paint(ansiRed, "! playlist replaced: no video is left from the previous run"))
+	}
+	for _, video := range changes.Added {
+		fmt.Fprintln(&b, paint(ansiGreen, "+ "+video.Title))
+	}
+	for _, video := range changes.Removed {
+		fmt.Fprintln(&b, paint(ansiRed, "- "+video.Title))
+	}
+	for _, rename := range changes.Renamed {
+		fmt.Fprintln(&b, paint(ansiYellow, "~ "+rename.OldTitle+" → "+rename.Video.Title))
+	}
+	io.WriteString(w, b.String())
+}
+
+// printSummaries prints the summary of every playlist of result that could be
+// compared.
+func printSummaries(w io.Writer, result RunResult, color bool) {
+	for _, playlist := range result.Playlists {
+		if playlist.Changes != nil {
+			name := playlist.Name
+			if result.RunName != "" {
+				name = result.RunName + ": " + name
+			}
+			printSummary(w, name, playlist.Changes, color)
+		}
+	}
+}
+
+// printDecisions prints the -explain trace of every playlist.
+func printDecisions(w io.Writer, result RunResult) {
+	for _, playlist := range result.Playlists {
+		fmt.Fprintf(w, "%s:\n", playlist.Name)
+		for _, decision := range playlist.Decisions {
+			fmt.Fprintf(w, "  %s\n", decision)
+		}
+		if playlist.Error != "" {
+			fmt.Fprintf(w, "  stopped: %s\n", playlist.Error)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+)
+
+// syslogPriorities are the SyslogPriority values, from most to least severe.
+var syslogPriorities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// logChangesToSyslog sends a line per changed playlist of results, such as
+// "music: 2 added, 1 removed, 0 renamed, 0 moved", to the system log, or to
+// stderr where there is none.
+func (config Config) logChangesToSyslog(results []PlaylistResult) {
+	var w io.Writer = os.Stderr
+	writer, err := newSyslogWriter(config.SyslogPriority, config.SyslogTag)
+	if err != nil {
+		log.Printf("WARNING: error connecting to syslog, writing to stderr instead: %v", err)
+	} else {
+		defer writer.Close()
+		w = writer
+	}
+
+	for _, result := range results {
+		if result.Changes == nil || result.Changes.empty() {
+			continue
+		}
+		changes := result.Changes
+		fmt.Fprintf(w, "%s%s: %d added, %d removed, %d renamed, %d moved\n", result.Name, runLabel(config.RunName),
+			len(changes.Added), len(changes.Removed), len(changes.Renamed), len(changes.Moved))
+	}
+}
+
+func validateSyslogPriority(priority string) error {
+	if priority != "" && !slices.Contains(syslogPriorities, priority) {
+		return fmt.Errorf("syslogPriority must be one of %v, got %q", syslogPriorities, priority)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// newSyslogWriter always fails where log/syslog is not available, so the
+// changes go to stderr.
+func newSyslogWriter(priority, tag string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+	"slices"
+)
+
+// newSyslogWriter connects to the local syslog daemon, logging as the user
+// facility at priority.
+func newSyslogWriter(priority, tag string) (io.WriteCloser, error) {
+	severity := syslog.Priority(slices.Index(syslogPriorities, priority))
+	return syslog.New(syslog.LOG_USER|severity, tag)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"watchURL": watchURL,
+	"formatTime": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// formatCount and formatDuration follow ReportLocale, see localeFuncs.
+	"formatCount":    numberLocale{}.formatInt,
+	"formatDuration": numberLocale{}.formatDuration,
+}
+
+func localeFuncs(locale numberLocale) template.FuncMap {
+	return template.FuncMap{"formatCount": locale.formatInt, "formatDuration": locale.formatDuration}
+}
+
+func parseTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
+
+// writeTemplate executes the user's TemplateFile with the playlist as data.
+func writeTemplate(w io.Writer, playlist *YoutubePlaylist, options writeOptions) error {
+	if options.templateFile == "" {
+		return errors.New("the template output format needs templateFile")
+	}
+	tmpl, err := parseTemplate(options.templateFile)
+	if err != nil {
+		return err
+	}
+	return tmpl.Funcs(localeFuncs(options.locale)).Execute(w, playlist)
+}
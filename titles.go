@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// titleResolver recalls the titles videos had in the history snapshots, for
+// videos the API now only names "Deleted video" or "Private video".
+type titleResolver struct {
+	deletedTitles []string
+	titles        map[string]knownTitle
+}
+
+type knownTitle struct {
+	title string
+	seen  time.Time
+}
+
+// newTitleResolver reads the stored playlist, the history snapshots of the
+// playlist and diff files and HistoryArchiveFileName of config.
+func newTitleResolver(config *Config) (*titleResolver, error) {
+	resolver := &titleResolver{deletedTitles: config.DeletedTitles, titles: make(map[string]knownTitle)}
+
+	archive, err := config.readArchive()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", config.HistoryArchiveFileName, err)
+	}
+	for _, snapshot := range archive {
+		resolver.add(snapshot.Snapshot, snapshot.TakenAt)
+	}
+
+	for _, fileName := range []string{config.PlaylistFileName, config.DiffFileName} {
+		paths, err := filepath.Glob(filepath.Join(config.DirPath, "*_"+fileName))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			name := filepath.Base(path)
+			takenAt, ok := historySnapshotTime(name, fileName)
+			if !ok {
+				continue
+			}
+			snapshot, err := readPlaylistFromFile(*config, name)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", name, err)
+			}
+			resolver.add(snapshot, takenAt)
+		}
+	}
+
+	current, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error reading %s: %w", config.PlaylistFileName, err)
+	}
+	resolver.add(current, current.UpdatedAt)
+	return resolver, nil
+}
+
+// add records the titles of snapshot, taken at seen, that are more recent
+// than the ones already known.
+func (resolver *titleResolver) add(snapshot YoutubePlaylist, seen time.Time) {
+	for _, video := range snapshot.Playlist {
+		if !resolver.recoverable(video.Title) {
+			continue
+		}
+		if known, found := resolver.titles[video.VideoId]; found && known.seen.After(seen) {
+			continue
+		}
+		resolver.titles[video.VideoId] = knownTitle{title: video.Title, seen: seen}
+	}
+}
+
+func (resolver *titleResolver) recoverable(title string) bool {
+	return title != "" && title != "Private video" && !slices.Contains(resolver.deletedTitles, title)
+}
+
+// bestKnownTitle returns the most recent title of videoId that was not a
+// deleted or private placeholder, reporting false when none was recorded.
+func (resolver *titleResolver) bestKnownTitle(videoId string) (string, bool) {
+	known, found := resolver.titles[videoId]
+	return known.title, found
+}
+
+// recoverTitles replaces the placeholder titles of videos with their best
+// known one.
+func (resolver *titleResolver) recoverTitles(videos []Video) {
+	for i, video := range videos {
+		if resolver.recoverable(video.Title) {
+			continue
+		}
+		if title, found := resolver.bestKnownTitle(video.VideoId); found {
+			videos[i].Title = title
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// writeUnified renders changes as a unified diff for diff viewers, with one
+// "VideoId  Title" line per video: removed videos and old titles as - lines,
+// added videos and new titles as + lines. Moves change no line and are left
+// out. No changes give an empty diff, like diff(1).
+func writeUnified(w io.Writer, changes *Changes, _ writeOptions) error {
+	if len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Renamed) == 0 {
+		return nil
+	}
+
+	out := bufio.NewWriter(w)
+	removed := len(changes.Removed) + len(changes.Renamed)
+	added := len(changes.Added) + len(changes.Renamed)
+	fmt.Fprintln(out, "--- a/playlist")
+	fmt.Fprintf(out, "+++ b/playlist\t%s\n", changes.UpdatedAt.Format(time.RFC3339))
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(removed), hunkRange(added))
+	for _, video := range changes.Removed {
+		fmt.Fprintf(out, "-%s  %s\n", video.VideoId, video.Title)
+	}
+	for _, rename := range changes.Renamed {
+		fmt.Fprintf(out, "-%s  %s\n", rename.Video.VideoId, rename.OldTitle)
+	}
+	for _, video := range changes.Added {
+		fmt.Fprintf(out, "+%s  %s\n", video.VideoId, video.Title)
+	}
+	for _, rename := range changes.Renamed {
+		fmt.Fprintf(out, "+%s  %s\n", rename.Video.VideoId, rename.Video.Title)
+	}
+	return out.Flush()
+}
+
+// hunkRange formats the start,count of a hunk, where an empty side starts at
+// line 0.
+func hunkRange(count int) string {
+	if count == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", count)
+}
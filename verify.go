@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const oembedURL = "https://www.youtube.com/oembed"
+
+// VerifiedVideo is one entry of the -verify availability report.
+type VerifiedVideo struct {
+	VideoId      string `json:"videoId"`
+	Title        string `json:"title"`
+	Availability string `json:"availability"`
+	StatusCode   int    `json:"statusCode,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// probeVideo checks whether a video can still be watched through the oEmbed
+// endpoint, which needs no API key or quota: it answers 401 or 403 for
+// private videos and 404 or 400 for removed ones.
+func probeVideo(client *http.Client, videoId string) VerifiedVideo {
+	result := VerifiedVideo{VideoId: videoId}
+	params := url.Values{}
+	params.Set("url", watchURL(videoId))
+	params.Set("format", "json")
+
+	resp, err := client.Get(oembedURL + "?" + params.Encode())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Availability = availabilityPublic
+	case http.StatusUnauthorized, http.StatusForbidden:
+		result.Availability = availabilityPrivate
+	case http.StatusNotFound, http.StatusBadRequest:
+		result.Availability = availabilityDeleted
+	default:
+		result.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+	}
+	return result
+}
+
+// verifyPlaylists probes every video of the stored playlists and writes a
+// report next to each, named after PlaylistFileName with a .verify.json
+// extension. Up to Concurrency videos are probed at once.
+func verifyPlaylists(config *Config) error {
+	configs, err := config.playlistConfigs()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: time.Duration(config.RequestTimeout)}
+	for _, config := range configs {
+		playlist, err := readPlaylistFromFile(*config, config.PlaylistFileName)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", config.PlaylistFileName, err)
+		}
+
+		report := make([]VerifiedVideo, len(playlist.Playlist))
+		slots := make(chan struct{}, max(config.Concurrency, 1))
+		var wg sync.WaitGroup
+		for i, video := range playlist.Playlist {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				report[i] = probeVideo(client, video.VideoId)
+				report[i].Title = video.Title
+			}()
+		}
+		wg.Wait()
+
+		config.writeOutput(baseName(config.PlaylistFileName)+".verify.json", "Availability report", func(w io.Writer) error {
+			return encodeJSON(w, report, config.writeOptions())
+		})
+	}
+	return nil
+}